@@ -0,0 +1,89 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package hostname turns the hostnames reported by providers into valid
+// host names and sets them on the running system.
+package hostname
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"syscall"
+)
+
+// Policies choosing which part of a fully qualified hostname is used.
+const (
+	Short = "short"
+	FQDN  = "fqdn"
+)
+
+const (
+	maxLabelLength = 63
+	// HOST_NAME_MAX of the kernel
+	maxLength = 64
+)
+
+// ErrEmpty is returned by Normalize for names with no valid characters.
+var ErrEmpty = errors.New("hostname has no valid characters")
+
+// Normalize sanitizes name and applies the policy to it. Labels are
+// lowercased, characters other than letters, digits and hyphens are replaced
+// with hyphens and each label is truncated to 63 characters. With the FQDN
+// policy, the short name is used if the full name does not fit in the 64
+// characters allowed by the kernel.
+func Normalize(name, policy string) (string, error) {
+	switch policy {
+	case Short, FQDN:
+	default:
+		return "", fmt.Errorf("unknown hostname policy %q", policy)
+	}
+
+	var labels []string
+	for _, label := range strings.Split(strings.ToLower(strings.TrimSpace(name)), ".") {
+		if label = sanitizeLabel(label); label != "" {
+			labels = append(labels, label)
+		}
+	}
+	if len(labels) == 0 {
+		return "", ErrEmpty
+	}
+
+	fqdn := strings.Join(labels, ".")
+	if policy == Short || len(fqdn) > maxLength {
+		return labels[0], nil
+	}
+	return fqdn, nil
+}
+
+// Set sets the transient hostname of the running system with
+// sethostname(2), which systemd-hostnamed picks up.
+func Set(name string) error {
+	return syscall.Sethostname([]byte(name))
+}
+
+func sanitizeLabel(label string) string {
+	sanitized := []byte(label)
+	for i, c := range sanitized {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-') {
+			sanitized[i] = '-'
+		}
+	}
+
+	label = strings.Trim(string(sanitized), "-")
+	if len(label) > maxLabelLength {
+		label = strings.TrimRight(label[:maxLabelLength], "-")
+	}
+	return label
+}
@@ -0,0 +1,43 @@
+package hostname
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	long := strings.Repeat("a", 70)
+
+	tests := []struct {
+		name   string
+		policy string
+		out    string
+		err    bool
+	}{
+		{name: "ip-10-0-0-1.ec2.internal", policy: FQDN, out: "ip-10-0-0-1.ec2.internal"},
+		{name: "ip-10-0-0-1.ec2.internal", policy: Short, out: "ip-10-0-0-1"},
+		{name: " Web_01.Example.com.\n", policy: FQDN, out: "web-01.example.com"},
+		{name: "node..example.com", policy: FQDN, out: "node.example.com"},
+		{name: long + ".example.com", policy: FQDN, out: strings.Repeat("a", 63)},
+		{name: "node." + strings.Repeat("b", 60) + ".example.com", policy: FQDN, out: "node"},
+		{name: "...", policy: FQDN, err: true},
+		{name: "node", policy: "long", err: true},
+	}
+
+	for _, test := range tests {
+		out, err := Normalize(test.name, test.policy)
+		if test.err {
+			if err == nil {
+				t.Errorf("%q (%s): expected an error, got %q", test.name, test.policy, out)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q (%s): unexpected error: %v", test.name, test.policy, err)
+			continue
+		}
+		if out != test.out {
+			t.Errorf("%q (%s): want %q, got %q", test.name, test.policy, test.out, out)
+		}
+	}
+}
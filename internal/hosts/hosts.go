@@ -0,0 +1,169 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package hosts maintains a block of entries for the machine's own names
+// within a hosts file, leaving the rest of the file untouched.
+package hosts
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/coreos/coreos-metadata/internal/providers"
+)
+
+const (
+	beginMarker = "# BEGIN coreos-metadata managed block"
+	endMarker   = "# END coreos-metadata managed block"
+)
+
+// Selections of the machine's addresses which may be mapped to its names.
+const (
+	Private = "private"
+	Public  = "public"
+	All     = "all"
+)
+
+var privateNets = []string{
+	"10.0.0.0/8",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"100.64.0.0/10",
+	"fc00::/7",
+}
+
+// Entry maps an address to the names of the machine.
+type Entry struct {
+	Address net.IP
+	Names   []string
+}
+
+// Entries maps the selected addresses of the machine to its hostname and, if
+// that is fully qualified, its short name. The addresses are the private and
+// public IPv4 addresses reported by the provider, followed by those of the
+// network config. Interface addresses are private or public according to the
+// interface's role, or their range if it has none.
+func Entries(metadata providers.Metadata, selection string) ([]Entry, error) {
+	if metadata.Hostname == "" {
+		return nil, nil
+	}
+	switch selection {
+	case Private, Public, All:
+	default:
+		return nil, fmt.Errorf("unknown address selection %q", selection)
+	}
+
+	names := []string{metadata.Hostname}
+	if i := strings.Index(metadata.Hostname, "."); i > 0 {
+		names = append(names, metadata.Hostname[:i])
+	}
+
+	type candidate struct {
+		ip      net.IP
+		private bool
+	}
+	var candidates []candidate
+	if ip := metadata.Common.PrivateIPv4; ip != nil {
+		candidates = append(candidates, candidate{ip, true})
+	}
+	if ip := metadata.Common.PublicIPv4; ip != nil {
+		candidates = append(candidates, candidate{ip, false})
+	}
+	for _, iface := range metadata.Network {
+		for _, addr := range iface.IPAddresses {
+			private := isPrivate(addr.IP)
+			switch iface.Role {
+			case Private:
+				private = true
+			case Public:
+				private = false
+			}
+			candidates = append(candidates, candidate{addr.IP, private})
+		}
+	}
+
+	var entries []Entry
+	seen := map[string]bool{}
+	for _, c := range candidates {
+		if c.ip.IsLoopback() || c.ip.IsUnspecified() || c.ip.IsLinkLocalUnicast() || seen[c.ip.String()] {
+			continue
+		}
+		seen[c.ip.String()] = true
+
+		if (selection == Private && !c.private) || (selection == Public && c.private) {
+			continue
+		}
+		entries = append(entries, Entry{Address: c.ip, Names: names})
+	}
+
+	return entries, nil
+}
+
+// Update returns the hosts file with the managed block replaced by one
+// containing the entries. The block is appended if the file does not have
+// one yet, and removed if there are no entries. A file with a begin marker
+// but no end marker is rejected, as the extent of the block is unknown.
+func Update(hosts []byte, entries []Entry) ([]byte, error) {
+	var block bytes.Buffer
+	if len(entries) > 0 {
+		fmt.Fprintln(&block, beginMarker)
+		for _, entry := range entries {
+			fmt.Fprintf(&block, "%s\t%s\n", entry.Address, strings.Join(entry.Names, " "))
+		}
+		fmt.Fprintln(&block, endMarker)
+	}
+
+	var before, after []string
+	lines := strings.SplitAfter(string(hosts), "\n")
+	begin, end := -1, -1
+	for i, line := range lines {
+		switch strings.TrimSpace(line) {
+		case beginMarker:
+			if begin < 0 {
+				begin = i
+			}
+		case endMarker:
+			if begin >= 0 && end < 0 {
+				end = i
+			}
+		}
+	}
+
+	switch {
+	case begin >= 0 && end >= 0:
+		before, after = lines[:begin], lines[end+1:]
+	case begin >= 0:
+		return nil, fmt.Errorf("managed block on line %d has no %q line", begin+1, endMarker)
+	default:
+		before = lines
+	}
+
+	head := strings.Join(before, "")
+	if head != "" && !strings.HasSuffix(head, "\n") && block.Len() > 0 {
+		head += "\n"
+	}
+	return []byte(head + block.String() + strings.Join(after, "")), nil
+}
+
+func isPrivate(ip net.IP) bool {
+	for _, cidr := range privateNets {
+		_, n, _ := net.ParseCIDR(cidr)
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,131 @@
+package hosts
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/coreos/coreos-metadata/internal/providers"
+)
+
+func TestEntries(t *testing.T) {
+	metadata := providers.Metadata{
+		Hostname: "node1.example.com",
+		Attributes: map[string]string{
+			// not addresses of the machine
+			"DIGITALOCEAN_IPV4_ANCHOR_0": "10.17.0.5",
+			"QEMU_ETCD_PEER":             "192.0.2.7",
+		},
+		Common: providers.Common{
+			PrivateIPv4: net.ParseIP("10.0.0.5"),
+			PublicIPv4:  net.ParseIP("203.0.113.5"),
+		},
+		Network: []providers.NetworkInterface{
+			{
+				IPAddresses: []net.IPNet{
+					{IP: net.ParseIP("10.0.0.5"), Mask: net.CIDRMask(24, 32)},
+					{IP: net.ParseIP("fe80::1"), Mask: net.CIDRMask(64, 128)},
+				},
+			},
+			{
+				// a private network using public addresses
+				Role: "private",
+				IPAddresses: []net.IPNet{
+					{IP: net.ParseIP("198.51.100.5"), Mask: net.CIDRMask(24, 32)},
+				},
+			},
+		},
+	}
+	names := []string{"node1.example.com", "node1"}
+
+	tests := []struct {
+		selection string
+		entries   []Entry
+	}{
+		{
+			selection: Private,
+			entries:   []Entry{{net.ParseIP("10.0.0.5"), names}, {net.ParseIP("198.51.100.5"), names}},
+		},
+		{
+			selection: Public,
+			entries:   []Entry{{net.ParseIP("203.0.113.5"), names}},
+		},
+		{
+			selection: All,
+			entries: []Entry{
+				{net.ParseIP("10.0.0.5"), names},
+				{net.ParseIP("203.0.113.5"), names},
+				{net.ParseIP("198.51.100.5"), names},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		entries, err := Entries(metadata, test.selection)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.selection, err)
+			continue
+		}
+		if !reflect.DeepEqual(entries, test.entries) {
+			t.Errorf("%s: bad entries: want %v, got %v", test.selection, test.entries, entries)
+		}
+	}
+
+	if _, err := Entries(metadata, "some"); err == nil {
+		t.Error("expected an error for an unknown selection")
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	entries := []Entry{{net.ParseIP("10.0.0.5"), []string{"node1.example.com", "node1"}}}
+	block := beginMarker + "\n10.0.0.5\tnode1.example.com node1\n" + endMarker + "\n"
+
+	tests := []struct {
+		desc    string
+		hosts   string
+		entries []Entry
+		out     string
+	}{
+		{
+			desc:    "empty file",
+			entries: entries,
+			out:     block,
+		},
+		{
+			desc:    "append without trailing newline",
+			hosts:   "127.0.0.1\tlocalhost",
+			entries: entries,
+			out:     "127.0.0.1\tlocalhost\n" + block,
+		},
+		{
+			desc:    "replace",
+			hosts:   "127.0.0.1\tlocalhost\n" + beginMarker + "\n10.0.0.9\told\n" + endMarker + "\n::1\tlocalhost\n",
+			entries: entries,
+			out:     "127.0.0.1\tlocalhost\n" + block + "::1\tlocalhost\n",
+		},
+		{
+			desc:  "remove",
+			hosts: "127.0.0.1\tlocalhost\n" + block,
+			out:   "127.0.0.1\tlocalhost\n",
+		},
+	}
+
+	for _, test := range tests {
+		out, err := Update([]byte(test.hosts), test.entries)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.desc, err)
+			continue
+		}
+		if string(out) != test.out {
+			t.Errorf("%s: bad hosts file:\nwant %q\ngot  %q", test.desc, test.out, out)
+		}
+		if again, err := Update(out, test.entries); err != nil || string(again) != string(out) {
+			t.Errorf("%s: update is not idempotent:\nwant %q\ngot  %q (%v)", test.desc, out, again, err)
+		}
+	}
+
+	truncated := "127.0.0.1\tlocalhost\n" + beginMarker + "\n10.0.0.9\told\n"
+	if out, err := Update([]byte(truncated), entries); err == nil {
+		t.Errorf("expected an error for a block without an end marker, got %q", out)
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2015 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lease
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	RetryInterval = 500 * time.Millisecond
+)
+
+// Find waits for systemd-networkd to write a DHCP lease for any of the
+// machine's interfaces and returns the first one found.
+func Find() (*os.File, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, fmt.Errorf("could not list interfaces: %v", err)
+	}
+
+	for {
+		for _, iface := range ifaces {
+			lease, err := os.Open(fmt.Sprintf("/run/systemd/netif/leases/%d", iface.Index))
+			if os.IsNotExist(err) {
+				continue
+			} else if err != nil {
+				return nil, err
+			} else {
+				return lease, nil
+			}
+		}
+
+		fmt.Printf("No leases found. Waiting...")
+		time.Sleep(RetryInterval)
+	}
+}
+
+// GetOption waits for a DHCP lease and returns the value of the given key
+// (e.g. "SERVER_ADDRESS" or "OPTION_245"). An empty string is returned if
+// the lease does not contain the key.
+func GetOption(key string) (string, error) {
+	lease, err := Find()
+	if err != nil {
+		return "", err
+	}
+	defer lease.Close()
+
+	return parseOption(lease, key)
+}
+
+func parseOption(lease io.Reader, key string) (string, error) {
+	line := bufio.NewScanner(lease)
+	for line.Scan() {
+		parts := strings.SplitN(line.Text(), "=", 2)
+		if parts[0] == key && len(parts) == 2 {
+			return parts[1], nil
+		}
+	}
+
+	return "", line.Err()
+}
@@ -0,0 +1,35 @@
+package lease
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseOption(t *testing.T) {
+	const lease = `# This is private data. Do not parse.
+ADDRESS=10.0.0.4
+NETMASK=255.255.255.0
+ROUTER=10.0.0.1
+SERVER_ADDRESS=168.63.129.16
+OPTION_245=a83f8110
+`
+
+	tests := []struct {
+		key   string
+		value string
+	}{
+		{key: "SERVER_ADDRESS", value: "168.63.129.16"},
+		{key: "OPTION_245", value: "a83f8110"},
+		{key: "OPTION_246", value: ""},
+	}
+
+	for _, tt := range tests {
+		value, err := parseOption(strings.NewReader(lease), tt.key)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.key, err)
+		}
+		if value != tt.value {
+			t.Errorf("%s:\nwant: %q\n got: %q", tt.key, tt.value, value)
+		}
+	}
+}
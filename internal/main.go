@@ -15,6 +15,8 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
@@ -24,13 +26,29 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/coreos/coreos-metadata/internal/hostname"
+	"github.com/coreos/coreos-metadata/internal/hosts"
+	"github.com/coreos/coreos-metadata/internal/netlink"
 	"github.com/coreos/coreos-metadata/internal/providers"
+	"github.com/coreos/coreos-metadata/internal/providers/aliyun"
 	"github.com/coreos/coreos-metadata/internal/providers/azure"
+	"github.com/coreos/coreos-metadata/internal/providers/cloudstack"
 	"github.com/coreos/coreos-metadata/internal/providers/digitalocean"
 	"github.com/coreos/coreos-metadata/internal/providers/ec2"
 	"github.com/coreos/coreos-metadata/internal/providers/gce"
+	"github.com/coreos/coreos-metadata/internal/providers/hetzner"
+	"github.com/coreos/coreos-metadata/internal/providers/ibmcloud"
+	"github.com/coreos/coreos-metadata/internal/providers/linode"
+	"github.com/coreos/coreos-metadata/internal/providers/nocloud"
+	"github.com/coreos/coreos-metadata/internal/providers/oci"
+	"github.com/coreos/coreos-metadata/internal/providers/opennebula"
 	"github.com/coreos/coreos-metadata/internal/providers/openstackMetadata"
 	"github.com/coreos/coreos-metadata/internal/providers/packet"
+	"github.com/coreos/coreos-metadata/internal/providers/qemu"
+	"github.com/coreos/coreos-metadata/internal/providers/scaleway"
+	"github.com/coreos/coreos-metadata/internal/providers/vmware"
+	"github.com/coreos/coreos-metadata/internal/providers/vultr"
+	"github.com/coreos/coreos-metadata/internal/render"
 
 	"github.com/coreos/update-ssh-keys/authorized_keys_d"
 )
@@ -40,31 +58,72 @@ var (
 	versionString = fmt.Sprintf("coreos-metadata %s", version)
 
 	ErrUnknownProvider = errors.New("unknown provider")
+	ErrNoBootCheckin   = errors.New("provider does not support boot check-in")
 )
 
 const (
-	cmdlinePath    = "/proc/cmdline"
-	cmdlineOEMFlag = "coreos.oem.id"
+	cmdlinePath           = "/proc/cmdline"
+	cmdlineOEMFlag        = "coreos.oem.id"
+	cmdlineDatasourceFlag = "ds"
+
+	resolvConfPath    = "/etc/resolv.conf"
+	networkUnitHeader = "# Generated by coreos-metadata. Do not edit.\n\n"
 )
 
 func main() {
 	flags := struct {
-		attributes   string
-		cmdline      bool
-		hostname     string
-		networkUnits string
-		provider     string
-		sshKeys      string
-		version      bool
+		applyNetwork   bool
+		attributes     string
+		cmdline        bool
+		dryRun         bool
+		hostname       string
+		hostnamePolicy string
+		hostsAddrs     string
+		hostsFile      string
+		networkUnits   string
+		neutralAttrs   bool
+		linkNames      bool
+		listAttrs      string
+		unitsPrefix    string
+		nocloudSeed    string
+		oneContext     string
+		phoneHome      bool
+		provider       string
+		resolvConf     string
+		resolved       string
+		setHostname    bool
+		sshKeys        string
+		templates      templateFlag
+		version        bool
+		vmwareDir      string
+		volumes        string
 	}{}
 
+	flag.BoolVar(&flags.applyNetwork, "apply-network", false, "Configure the network interfaces directly through netlink")
 	flag.StringVar(&flags.attributes, "attributes", "", "The file into which the metadata attributes are written")
 	flag.BoolVar(&flags.cmdline, "cmdline", false, "Read the cloud provider from the kernel cmdline")
+	flag.BoolVar(&flags.dryRun, "dry-run", false, "Print the changes --apply-network would make instead of making them")
 	flag.StringVar(&flags.hostname, "hostname", "", "The file into which the hostname should be written")
+	flag.BoolVar(&flags.linkNames, "network-link-names", false, "Write .link units naming interfaces after their role (e.g. public0)")
+	flag.StringVar(&flags.hostnamePolicy, "hostname-policy", "", "Sanitize the hostname and use its short or fully qualified form: short or fqdn (the default with --set-hostname)")
+	flag.StringVar(&flags.hostsAddrs, "hosts-addresses", hosts.Private, "The addresses mapped to the hostname in --hosts-file: private, public or all")
+	flag.StringVar(&flags.hostsFile, "hosts-file", "", "The hosts file in which entries for the hostname are maintained")
+	flag.StringVar(&flags.listAttrs, "list-attributes", "", "Print the attributes written for the given cloud provider and exit")
 	flag.StringVar(&flags.networkUnits, "network-units", "", "The directory into which network units are written")
+	flag.StringVar(&flags.unitsPrefix, "network-units-prefix", "00", "The priority prefix of the generated network unit file names")
+	flag.BoolVar(&flags.neutralAttrs, "neutral-attributes", false, "Also write provider-neutral attributes, e.g. COREOS_CUSTOM_PRIVATE_IPV4")
+	flag.StringVar(&flags.nocloudSeed, "nocloud-seed", "", "The directory or URL containing the NoCloud seed")
+	flag.StringVar(&flags.oneContext, "opennebula-context", "", "The OpenNebula context.sh to read instead of the one on the CONTEXT CD-ROM")
+	flag.BoolVar(&flags.phoneHome, "phone-home", false, "Notify the cloud provider that the machine has booted")
 	flag.StringVar(&flags.provider, "provider", "", "The name of the cloud provider")
+	flag.StringVar(&flags.resolvConf, "resolv-conf", "", "The file into which the nameservers are written in resolv.conf format")
+	flag.StringVar(&flags.resolved, "resolved-dropin", "", "The file into which the nameservers are written as a systemd-resolved drop-in")
+	flag.BoolVar(&flags.setHostname, "set-hostname", false, "Set the transient hostname of the running system")
 	flag.StringVar(&flags.sshKeys, "ssh-keys", "", "Update SSH keys for the given user")
+	flag.Var(&flags.templates, "template", "A template to render with the metadata, given as src:dest (may be repeated)")
 	flag.BoolVar(&flags.version, "version", false, "Print the version and exit")
+	flag.StringVar(&flags.vmwareDir, "vmware-guestinfo-dir", "", "The directory from which VMware guestinfo variables are read instead of vmware-rpctool")
+	flag.StringVar(&flags.volumes, "volumes", "", "The file into which attached block volumes are written as JSON")
 
 	flag.Parse()
 
@@ -73,14 +132,31 @@ func main() {
 		return
 	}
 
-	if flags.cmdline && flags.provider == "" {
-		args, err := ioutil.ReadFile(cmdlinePath)
+	if flags.listAttrs != "" {
+		schema, err := getAttributeSchema(flags.listAttrs)
 		if err != nil {
+			fmt.Fprintf(os.Stderr, "invalid provider %q\n", flags.listAttrs)
+			os.Exit(2)
+		}
+		fmt.Print(schema.Markdown())
+		return
+	}
+
+	var args []byte
+	if flags.cmdline || flags.provider == "nocloud" {
+		var err error
+		args, err = ioutil.ReadFile(cmdlinePath)
+		if err != nil && flags.cmdline {
 			fmt.Fprintf(os.Stderr, "could not read cmdline: %v\n", err)
 			os.Exit(2)
 		}
+	}
 
-		flags.provider = parseCmdline(args)
+	if flags.cmdline && flags.provider == "" {
+		flags.provider = parseCmdline(args, cmdlineOEMFlag)
+		if _, ok := nocloud.ParseDatasource(parseCmdline(args, cmdlineDatasourceFlag)); ok && flags.provider == "" {
+			flags.provider = "nocloud"
+		}
 	}
 
 	metadataFn, err := getMetadataProvider(flags.provider)
@@ -89,13 +165,54 @@ func main() {
 		os.Exit(2)
 	}
 
+	switch {
+	case flags.provider == "nocloud":
+		seed := flags.nocloudSeed
+		if seed == "" {
+			seed, _ = nocloud.ParseDatasource(parseCmdline(args, cmdlineDatasourceFlag))
+		}
+
+		metadataFn = func() (providers.Metadata, error) {
+			return nocloud.FetchMetadataFrom(seed)
+		}
+	case flags.provider == "opennebula" && flags.oneContext != "":
+		metadataFn = func() (providers.Metadata, error) {
+			return opennebula.FetchMetadataFrom(flags.oneContext)
+		}
+	case flags.provider == "vmware" && flags.vmwareDir != "":
+		metadataFn = func() (providers.Metadata, error) {
+			return vmware.FetchMetadataFrom(vmware.FileBackend{Dir: flags.vmwareDir})
+		}
+	}
+
+	var checkinFn func(providers.Metadata) error
+	if flags.phoneHome {
+		checkinFn, err = getBootCheckin(flags.provider)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "cannot phone home with provider %q: %v\n", flags.provider, err)
+			os.Exit(2)
+		}
+	}
+
 	metadata, err := metadataFn()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "failed to fetch metadata: %v\n", err)
 		os.Exit(1)
 	}
 
-	if err := writeMetadataAttributes(flags.attributes, metadata); err != nil {
+	if schema, err := getAttributeSchema(flags.provider); err == nil {
+		if err := schema.Validate(metadata.Attributes); err != nil {
+			fmt.Fprintf(os.Stderr, "warning: unexpected metadata attributes: %v\n", err)
+		}
+	}
+
+	name, err := machineHostname(metadata.Hostname, flags.hostnamePolicy, flags.setHostname)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid hostname %q: %v\n", metadata.Hostname, err)
+		os.Exit(1)
+	}
+
+	if err := writeMetadataAttributes(flags.attributes, flags.neutralAttrs, name, metadata); err != nil {
 		fmt.Fprintf(os.Stderr, "failed to write metadata attributes: %v\n", err)
 		os.Exit(1)
 	}
@@ -105,28 +222,79 @@ func main() {
 		os.Exit(1)
 	}
 
-	if err := writeHostname(flags.hostname, metadata); err != nil {
+	if err := writeHostname(flags.hostname, name); err != nil {
 		fmt.Fprintf(os.Stderr, "failed to write hostname: %v\n", err)
 		os.Exit(1)
 	}
 
-	if err := writeNetworkUnits(flags.networkUnits, metadata); err != nil {
+	if err := setHostname(flags.setHostname, name); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to set hostname: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := writeHostsFile(flags.hostsFile, flags.hostsAddrs, name, metadata); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to update hosts file: %v\n", err)
+		os.Exit(1)
+	}
+
+	if flags.networkUnits != "" || flags.applyNetwork {
+		if metadata, err = validateNetwork(metadata); err != nil {
+			fmt.Fprintf(os.Stderr, "invalid network config: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if err := writeNetworkUnits(flags.networkUnits, flags.unitsPrefix, flags.linkNames, metadata); err != nil {
 		fmt.Fprintf(os.Stderr, "failed to write network units: %v\n", err)
 		os.Exit(1)
 	}
+
+	if flags.applyNetwork {
+		if err := applyNetwork(metadata, flags.dryRun); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to apply network config: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if err := writeResolvConf(flags.resolvConf, metadata); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write resolv.conf: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := writeResolvedDropin(flags.resolved, metadata); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write resolved drop-in: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := writeTemplates(flags.templates, metadata); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to render template: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := writeVolumes(flags.volumes, metadata); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write volumes: %v\n", err)
+		os.Exit(1)
+	}
+
+	if checkinFn != nil {
+		if err := checkinFn(metadata); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to phone home: %v\n", err)
+			os.Exit(1)
+		}
+	}
 }
 
-func parseCmdline(cmdline []byte) (oem string) {
+func parseCmdline(cmdline []byte, flag string) (value string) {
 	for _, arg := range strings.Split(string(cmdline), " ") {
 		parts := strings.SplitN(strings.TrimSpace(arg), "=", 2)
 		key := parts[0]
 
-		if key != cmdlineOEMFlag {
+		if key != flag {
 			continue
 		}
 
 		if len(parts) == 2 {
-			oem = parts[1]
+			value = parts[1]
 		}
 	}
 
@@ -135,23 +303,101 @@ func parseCmdline(cmdline []byte) (oem string) {
 
 func getMetadataProvider(providerName string) (func() (providers.Metadata, error), error) {
 	switch providerName {
+	case "aliyun":
+		return aliyun.FetchMetadata, nil
 	case "azure":
 		return azure.FetchMetadata, nil
+	case "cloudstack", "exoscale":
+		return cloudstack.FetchMetadata, nil
 	case "digitalocean":
 		return digitalocean.FetchMetadata, nil
 	case "ec2":
 		return ec2.FetchMetadata, nil
 	case "gce":
 		return gce.FetchMetadata, nil
+	case "hetzner":
+		return hetzner.FetchMetadata, nil
+	case "ibmcloud":
+		return ibmcloud.FetchMetadata, nil
+	case "linode":
+		return linode.FetchMetadata, nil
+	case "nocloud":
+		return nocloud.FetchMetadata, nil
+	case "oci":
+		return oci.FetchMetadata, nil
 	case "packet":
 		return packet.FetchMetadata, nil
+	case "opennebula":
+		return opennebula.FetchMetadata, nil
 	case "openstack-metadata":
 		return openstackMetadata.FetchMetadata, nil
+	case "qemu":
+		return qemu.FetchMetadata, nil
+	case "scaleway":
+		return scaleway.FetchMetadata, nil
+	case "vmware":
+		return vmware.FetchMetadata, nil
+	case "vultr":
+		return vultr.FetchMetadata, nil
 	default:
 		return nil, ErrUnknownProvider
 	}
 }
 
+func getAttributeSchema(providerName string) (providers.Schema, error) {
+	switch providerName {
+	case "aliyun":
+		return aliyun.Schema, nil
+	case "azure":
+		return azure.Schema, nil
+	case "cloudstack", "exoscale":
+		return cloudstack.Schema, nil
+	case "digitalocean":
+		return digitalocean.Schema, nil
+	case "ec2":
+		return ec2.Schema, nil
+	case "gce":
+		return gce.Schema, nil
+	case "hetzner":
+		return hetzner.Schema, nil
+	case "ibmcloud":
+		return ibmcloud.Schema, nil
+	case "linode":
+		return linode.Schema, nil
+	case "nocloud":
+		return nocloud.Schema, nil
+	case "oci":
+		return oci.Schema, nil
+	case "packet":
+		return packet.Schema, nil
+	case "opennebula":
+		return opennebula.Schema, nil
+	case "openstack-metadata":
+		return openstackMetadata.Schema, nil
+	case "qemu":
+		return qemu.Schema, nil
+	case "scaleway":
+		return scaleway.Schema, nil
+	case "vmware":
+		return vmware.Schema, nil
+	case "vultr":
+		return vultr.Schema, nil
+	default:
+		return nil, ErrUnknownProvider
+	}
+}
+
+func getBootCheckin(providerName string) (func(providers.Metadata) error, error) {
+	switch providerName {
+	case "packet":
+		return packet.PhoneHome, nil
+	case "scaleway":
+		return scaleway.ReportBooted, nil
+	default:
+		return nil, ErrNoBootCheckin
+	}
+}
+
 func writeVariable(out *os.File, key string, value string) (err error) {
 	if len(value) > 0 {
 		_, err = fmt.Fprintf(out, "COREOS_%s=%s\n", key, value)
@@ -159,7 +405,7 @@ func writeVariable(out *os.File, key string, value string) (err error) {
 	return
 }
 
-func writeMetadataAttributes(attributes string, metadata providers.Metadata) error {
+func writeMetadataAttributes(attributes string, neutral bool, name string, metadata providers.Metadata) error {
 	if attributes == "" {
 		return nil
 	}
@@ -181,6 +427,14 @@ func writeMetadataAttributes(attributes string, metadata providers.Metadata) err
 			return err
 		}
 	}
+	if !neutral {
+		return nil
+	}
+	for key, value := range metadata.CommonAttributes(name) {
+		if err := writeVariable(out, key, value); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -208,8 +462,27 @@ func writeMetadataKeys(username string, metadata providers.Metadata) error {
 	return akd.Sync()
 }
 
-func writeHostname(path string, metadata providers.Metadata) error {
-	if path == "" || metadata.Hostname == "" {
+// machineHostname returns the name which is written to the hostname file,
+// set on the running system, mapped in the hosts file and written as the
+// provider-neutral hostname attribute. The provider's hostname is used as
+// is, unless a policy is given or the name is set on the running system,
+// which requires a valid host name.
+func machineHostname(name, policy string, set bool) (string, error) {
+	if name == "" {
+		return "", nil
+	}
+	if policy == "" {
+		if !set {
+			return name, nil
+		}
+		policy = hostname.FQDN
+	}
+
+	return hostname.Normalize(name, policy)
+}
+
+func writeHostname(path, name string) error {
+	if path == "" || name == "" {
 		return nil
 	}
 
@@ -218,26 +491,306 @@ func writeHostname(path string, metadata providers.Metadata) error {
 		return err
 	}
 
-	return ioutil.WriteFile(path, []byte(metadata.Hostname), 0644)
+	return ioutil.WriteFile(path, []byte(name), 0644)
+}
+
+func setHostname(enabled bool, name string) error {
+	if !enabled || name == "" {
+		return nil
+	}
+
+	return hostname.Set(name)
+}
+
+func writeHostsFile(path, selection, name string, metadata providers.Metadata) error {
+	if path == "" || name == "" {
+		return nil
+	}
+
+	metadata.Hostname = name
+	entries, err := hosts.Entries(metadata, selection)
+	if err != nil {
+		return err
+	}
+
+	current, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	updated, err := hosts.Update(current, entries)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(current, updated) {
+		return nil
+	}
+
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	return replaceFile(path, updated, mode)
 }
 
-func writeNetworkUnits(root string, metadata providers.Metadata) error {
-	if root == "" || metadata.Network == nil {
+// writeNetworkUnits writes the units of the interfaces into root and removes
+// the ones written by a previous run for interfaces which have since gone,
+// including when there are no interfaces left at all.
+func writeNetworkUnits(root, prefix string, linkNames bool, metadata providers.Metadata) error {
+	if root == "" {
 		return nil
 	}
+	if !validUnitsPrefix(prefix) {
+		return fmt.Errorf("invalid unit prefix %q: only letters, digits, '-' and '_' are allowed", prefix)
+	}
 
 	err := os.MkdirAll(root, 0755)
 	if err != nil {
 		return err
 	}
 
-	for _, iface := range metadata.Network {
-		name := filepath.Join(root, fmt.Sprintf("00-%s.network", iface.HardwareAddress))
-		err := ioutil.WriteFile(name, []byte(iface.NetworkConfig()), 0644)
+	ifaces := metadata.Network
+	if linkNames {
+		ifaces = providers.AssignNames(ifaces)
+	}
+
+	written := map[string]bool{}
+	write := func(id, ext, config string) error {
+		name := filepath.Join(root, fmt.Sprintf("%s-%s.%s", prefix, id, ext))
+		written[name] = true
+		return ioutil.WriteFile(name, []byte(networkUnitHeader+config), 0644)
+	}
+
+	for _, iface := range providers.LinkMembers(ifaces) {
+		id := iface.HardwareAddress.String()
+		if iface.HardwareAddress == nil || iface.Kind != providers.Physical || (linkNames && iface.Role != "") {
+			id = iface.Name
+		}
+
+		if err := write(id, "network", iface.NetworkConfig()); err != nil {
+			return err
+		}
+		if netdev := iface.NetdevConfig(); netdev != "" {
+			if err := write(id, "netdev", netdev); err != nil {
+				return err
+			}
+		}
+		if link := iface.LinkConfig(); linkNames && link != "" {
+			if err := write(id, "link", link); err != nil {
+				return err
+			}
+		}
+	}
+
+	return removeStaleNetworkUnits(root, written)
+}
+
+// validUnitsPrefix reports whether prefix can start a unit file name.
+func validUnitsPrefix(prefix string) bool {
+	if prefix == "" {
+		return false
+	}
+	for _, c := range prefix {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// removeStaleNetworkUnits deletes units in root which were generated by a
+// previous run but not by this one, e.g. for an interface which has since
+// been detached.
+func removeStaleNetworkUnits(root string, written map[string]bool) error {
+	entries, err := ioutil.ReadDir(root)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		name := filepath.Join(root, entry.Name())
+		switch filepath.Ext(name) {
+		case ".network", ".netdev", ".link":
+		default:
+			continue
+		}
+		if written[name] || !entry.Mode().IsRegular() {
+			continue
+		}
+
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		if !strings.HasPrefix(string(data), networkUnitHeader) {
+			continue
+		}
+		if err := os.Remove(name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validateNetwork checks and normalises the network config before it is
+// written or applied.
+func validateNetwork(metadata providers.Metadata) (providers.Metadata, error) {
+	if metadata.Network == nil {
+		return metadata, nil
+	}
+
+	network, err := providers.ValidateNetwork(metadata.Network)
+	if err != nil {
+		return metadata, err
+	}
+	metadata.Network = network
+	return metadata, nil
+}
+
+// applyNetwork configures the interfaces through netlink and writes their
+// nameservers to resolv.conf. With dryRun, the changes are only printed.
+func applyNetwork(metadata providers.Metadata, dryRun bool) error {
+	if metadata.Network == nil {
+		return nil
+	}
+
+	state, err := netlink.CurrentState()
+	if err != nil {
+		return err
+	}
+	changes, err := netlink.Plan(state, metadata.Network)
+	if err != nil {
+		return err
+	}
+
+	resolv := providers.ResolvConf(metadata.Network)
+	if current, err := ioutil.ReadFile(resolvConfPath); err == nil && string(current) == resolv {
+		resolv = ""
+	}
+
+	if dryRun {
+		for _, change := range changes {
+			fmt.Println(change)
+		}
+		for _, line := range strings.SplitAfter(resolv, "\n") {
+			if line != "" {
+				fmt.Printf("+ %s: %s", resolvConfPath, line)
+			}
+		}
+		return nil
+	}
+
+	if err := netlink.Apply(changes); err != nil {
+		return err
+	}
+	return writeResolvConf(resolvConfPath, metadata)
+}
+
+func writeResolvConf(path string, metadata providers.Metadata) error {
+	return writeDNSConfig(path, providers.ResolvConf(metadata.Network))
+}
+
+func writeResolvedDropin(path string, metadata providers.Metadata) error {
+	return writeDNSConfig(path, providers.ResolvedConfig(metadata.Network))
+}
+
+// writeDNSConfig replaces the file at path with config. If path is a
+// symlink, e.g. /etc/resolv.conf pointing at the systemd-resolved stub, the
+// link itself is replaced rather than the file it points to.
+func writeDNSConfig(path, config string) error {
+	if path == "" || config == "" {
+		return nil
+	}
+
+	return replaceFile(path, []byte(config), 0644)
+}
+
+// replaceFile writes data to a temporary file next to path and renames it
+// over path, so that readers never see a partially written file.
+func replaceFile(path string, data []byte, mode os.FileMode) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(dir, "."+filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+// templateFlag collects the src:dest pairs given with --template.
+type templateFlag [][2]string
+
+func (t *templateFlag) String() string {
+	var specs []string
+	for _, spec := range *t {
+		specs = append(specs, spec[0]+":"+spec[1])
+	}
+	return strings.Join(specs, ",")
+}
+
+func (t *templateFlag) Set(value string) error {
+	parts := strings.SplitN(value, ":", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return fmt.Errorf("expected src:dest, got %q", value)
+	}
+	*t = append(*t, [2]string{parts[0], parts[1]})
+	return nil
+}
+
+func writeTemplates(templates templateFlag, metadata providers.Metadata) error {
+	for _, spec := range templates {
+		src, dest := spec[0], spec[1]
+
+		out, err := render.File(src, metadata)
 		if err != nil {
 			return err
 		}
+
+		err = os.MkdirAll(filepath.Dir(dest), 0755)
+		if err != nil {
+			return err
+		}
+
+		if err := ioutil.WriteFile(dest, out, 0644); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
+
+func writeVolumes(path string, metadata providers.Metadata) error {
+	if path == "" || metadata.Volumes == nil {
+		return nil
+	}
+
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(metadata.Volumes, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, append(data, '\n'), 0644)
+}
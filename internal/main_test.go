@@ -1,8 +1,20 @@
 package main
 
 import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
 	"reflect"
+	"sort"
+	"strings"
 	"testing"
+
+	"github.com/coreos/coreos-metadata/internal/netlink"
+	"github.com/coreos/coreos-metadata/internal/providers"
 )
 
 func TestGetMetadataProvider(t *testing.T) {
@@ -35,3 +47,411 @@ func TestGetMetadataProvider(t *testing.T) {
 		}
 	}
 }
+
+var update = flag.Bool("update", false, "Regenerate the attribute tables in the README")
+
+// documentedProviders lists the providers in the order of the README.
+var documentedProviders = []string{
+	"aliyun",
+	"azure",
+	"cloudstack",
+	"digitalocean",
+	"ec2",
+	"gce",
+	"hetzner",
+	"ibmcloud",
+	"linode",
+	"nocloud",
+	"oci",
+	"packet",
+	"opennebula",
+	"openstack-metadata",
+	"qemu",
+	"scaleway",
+	"vmware",
+	"vultr",
+}
+
+func TestAttributeSchemas(t *testing.T) {
+	const (
+		readme = "../README.md"
+		begin  = "<!-- BEGIN GENERATED ATTRIBUTES -->\n"
+		end    = "<!-- END GENERATED ATTRIBUTES -->\n"
+	)
+
+	var generated bytes.Buffer
+	for _, name := range documentedProviders {
+		if _, err := getMetadataProvider(name); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+		schema, err := getAttributeSchema(name)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		fmt.Fprintf(&generated, "\n### %s\n\n%s", name, schema.Markdown())
+	}
+	generated.WriteString("\n")
+
+	text, err := ioutil.ReadFile(readme)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := bytes.Index(text, []byte(begin))
+	stop := bytes.Index(text, []byte(end))
+	if start < 0 || stop < start {
+		t.Fatalf("%s has no generated attributes section", readme)
+	}
+	start += len(begin)
+
+	if bytes.Equal(text[start:stop], generated.Bytes()) {
+		return
+	}
+	if !*update {
+		t.Fatalf("the attributes in %s are out of date; run the tests with -update", readme)
+	}
+	updated := append(append(append([]byte{}, text[:start]...), generated.Bytes()...), text[stop:]...)
+	if err := ioutil.WriteFile(readme, updated, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGetBootCheckin(t *testing.T) {
+	tests := []struct {
+		desc string
+		name string
+		err  error
+	}{
+		{
+			desc: "provider with check-in",
+			name: "packet",
+			err:  nil,
+		},
+		{
+			desc: "provider without check-in",
+			name: "ec2",
+			err:  ErrNoBootCheckin,
+		},
+		{
+			desc: "empty provider",
+			name: "",
+			err:  ErrNoBootCheckin,
+		},
+	}
+
+	for _, tt := range tests {
+		_, err := getBootCheckin(tt.name)
+		if !reflect.DeepEqual(err, tt.err) {
+			t.Errorf("%s:\nwant: %v\n got: %v", tt.desc, tt.err, err)
+		}
+	}
+}
+
+func TestParseCmdline(t *testing.T) {
+	const cmdline = "BOOT_IMAGE=/vmlinuz coreos.oem.id=qemu ds=nocloud;s=http://10.0.2.2:8000/ rootflags=rw\n"
+
+	tests := []struct {
+		flag  string
+		value string
+	}{
+		{flag: cmdlineOEMFlag, value: "qemu"},
+		{flag: cmdlineDatasourceFlag, value: "nocloud;s=http://10.0.2.2:8000/"},
+		{flag: "rootflags", value: "rw"},
+		{flag: "missing", value: ""},
+	}
+
+	for _, tt := range tests {
+		value := parseCmdline([]byte(cmdline), tt.flag)
+		if value != tt.value {
+			t.Errorf("%s:\nwant: %q\n got: %q", tt.flag, tt.value, value)
+		}
+	}
+}
+
+func TestWriteNetworkUnits(t *testing.T) {
+	root, err := ioutil.TempDir("", "network-units")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	// a unit from a previous run for a detached interface, and one owned by
+	// the administrator
+	stale := filepath.Join(root, "10-private1.network")
+	foreign := filepath.Join(root, "10-custom.network")
+	if err := ioutil.WriteFile(stale, []byte(networkUnitHeader), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(foreign, []byte("[Match]\nName=eth9\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	mac, _ := net.ParseMAC("52:54:00:12:34:00")
+	metadata := providers.Metadata{
+		Network: []providers.NetworkInterface{{HardwareAddress: mac, Role: "public"}},
+	}
+	if err := writeNetworkUnits(root, "10", true, metadata); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := ioutil.ReadDir(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+
+	expected := []string{"10-custom.network", "10-public0.link", "10-public0.network"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("bad units: want %v, got %v", expected, names)
+	}
+
+	// all interfaces are gone
+	if err := writeNetworkUnits(root, "10", true, providers.Metadata{}); err != nil {
+		t.Fatal(err)
+	}
+	entries, err = ioutil.ReadDir(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "10-custom.network" {
+		t.Fatalf("stale units were kept: %v", entries)
+	}
+
+	for _, prefix := range []string{"", "../10", "10/", "1 0"} {
+		if err := writeNetworkUnits(root, prefix, false, metadata); err == nil {
+			t.Errorf("prefix %q: expected an error", prefix)
+		}
+	}
+}
+
+func TestValidateAndPlanNetwork(t *testing.T) {
+	mac, _ := net.ParseMAC("52:54:00:12:34:00")
+	state := netlink.State{
+		Links: []netlink.Link{{Index: 2, Name: "eth0", HardwareAddress: mac, MTU: 1500, Up: true}},
+	}
+
+	// built the way the providers build them: digitalocean routes each
+	// subnet through its gateway with the interface address as the
+	// destination, and both use 16-byte default route masks
+	public := net.IPNet{IP: net.ParseIP("203.0.113.10"), Mask: net.IPMask(net.ParseIP("255.255.255.0").To4())}
+	anchor := net.IPNet{IP: net.ParseIP("10.0.0.5"), Mask: net.IPMask(net.ParseIP("255.255.0.0").To4())}
+	metadata := providers.Metadata{
+		Network: []providers.NetworkInterface{{
+			HardwareAddress: mac,
+			IPAddresses:     []net.IPNet{public, anchor},
+			Routes: []providers.NetworkRoute{
+				{Destination: public, Gateway: net.ParseIP("203.0.113.1")},
+				{
+					Destination: net.IPNet{IP: net.IPv4zero, Mask: net.IPMask(net.IPv4zero)},
+					Gateway:     net.ParseIP("203.0.113.1"),
+				},
+				{Destination: anchor, Gateway: net.ParseIP("10.0.0.1")},
+			},
+		}},
+	}
+
+	metadata, err := validateNetwork(metadata)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	changes, err := netlink.Plan(state, metadata.Network)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var diff []string
+	for _, change := range changes {
+		diff = append(diff, change.String())
+	}
+	expected := []string{
+		"+ eth0: address 203.0.113.10/24",
+		"+ eth0: address 10.0.0.5/16",
+		"+ eth0: route 203.0.113.0/24 via 203.0.113.1",
+		"+ eth0: route 0.0.0.0/0 via 203.0.113.1",
+		"+ eth0: route 10.0.0.0/16 via 10.0.0.1",
+	}
+	if !reflect.DeepEqual(diff, expected) {
+		t.Errorf("bad changes:\nwant: %q\n got: %q", expected, diff)
+	}
+
+	// once applied, the kernel reports the masked destinations, which must
+	// match so that a second run changes nothing
+	state.Addrs = []netlink.Addr{
+		{LinkIndex: 2, IPNet: net.IPNet{IP: net.IPv4(203, 0, 113, 10).To4(), Mask: net.CIDRMask(24, 32)}, Permanent: true},
+		{LinkIndex: 2, IPNet: net.IPNet{IP: net.IPv4(10, 0, 0, 5).To4(), Mask: net.CIDRMask(16, 32)}, Permanent: true},
+	}
+	state.Routes = []netlink.Route{
+		{LinkIndex: 2, Destination: net.IPNet{IP: net.IPv4(203, 0, 113, 0).To4(), Mask: net.CIDRMask(24, 32)}, Gateway: net.IPv4(203, 0, 113, 1).To4(), Static: true},
+		{LinkIndex: 2, Destination: net.IPNet{IP: net.IPv4zero.To4(), Mask: net.CIDRMask(0, 32)}, Gateway: net.IPv4(203, 0, 113, 1).To4(), Static: true},
+		{LinkIndex: 2, Destination: net.IPNet{IP: net.IPv4(10, 0, 0, 0).To4(), Mask: net.CIDRMask(16, 32)}, Gateway: net.IPv4(10, 0, 0, 1).To4(), Static: true},
+	}
+	if changes, err = netlink.Plan(state, metadata.Network); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, change := range changes {
+		t.Errorf("unexpected change on second run: %s", change)
+	}
+}
+
+func TestWriteHostsFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hosts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "hosts")
+	if err := ioutil.WriteFile(path, []byte("127.0.0.1 localhost\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	metadata := providers.Metadata{Common: providers.Common{PrivateIPv4: net.ParseIP("10.0.0.5")}}
+	if err := writeHostsFile(path, "private", "node1", metadata); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), "127.0.0.1 localhost\n") || !strings.Contains(string(data), "10.0.0.5") {
+		t.Errorf("bad hosts file: %q", data)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("file mode was not kept: %v, %v", info, err)
+	}
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("temporary files were left behind: %v", entries)
+	}
+}
+
+func TestWriteDNSConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "resolv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// resolv.conf pointing at the systemd-resolved stub
+	stub := filepath.Join(dir, "stub-resolv.conf")
+	path := filepath.Join(dir, "resolv.conf")
+	if err := ioutil.WriteFile(stub, []byte("nameserver 127.0.0.53\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(stub, path); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := writeDNSConfig(path, "nameserver 10.0.0.2\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	if data, err := ioutil.ReadFile(stub); err != nil || string(data) != "nameserver 127.0.0.53\n" {
+		t.Errorf("stub was modified: %q, %v", data, err)
+	}
+	if info, err := os.Lstat(path); err != nil || !info.Mode().IsRegular() || info.Mode().Perm() != 0644 {
+		t.Errorf("symlink was not replaced by a regular file: %v, %v", info, err)
+	}
+	if data, err := ioutil.ReadFile(path); err != nil || string(data) != "nameserver 10.0.0.2\n" {
+		t.Errorf("bad resolv.conf: %q, %v", data, err)
+	}
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Errorf("temporary files were left behind: %v", entries)
+	}
+}
+
+func TestWriteMetadataAttributes(t *testing.T) {
+	dir, err := ioutil.TempDir("", "attributes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	metadata := providers.Metadata{
+		Attributes: map[string]string{"TEST_HOSTNAME": "Node1.Example.com"},
+		Hostname:   "Node1.Example.com",
+	}
+	name, err := machineHostname(metadata.Hostname, "short", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "attributes")
+	if err := writeMetadataAttributes(path, true, name, metadata); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	sort.Strings(lines)
+	expected := []string{"COREOS_HOSTNAME=node1", "COREOS_TEST_HOSTNAME=Node1.Example.com"}
+	if !reflect.DeepEqual(lines, expected) {
+		t.Errorf("bad attributes:\nwant: %q\n got: %q", expected, lines)
+	}
+}
+
+func TestMachineHostname(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy string
+		set    bool
+		out    string
+	}{
+		{name: "Web_01.Example.com", out: "Web_01.Example.com"},
+		{name: "Web_01.Example.com", set: true, out: "web-01.example.com"},
+		{name: "Web_01.Example.com", policy: "short", out: "web-01"},
+		{name: "Web_01.Example.com", policy: "short", set: true, out: "web-01"},
+		{name: "", policy: "fqdn", set: true, out: ""},
+	}
+
+	for _, test := range tests {
+		out, err := machineHostname(test.name, test.policy, test.set)
+		if err != nil {
+			t.Errorf("%q (%q, %t): unexpected error: %v", test.name, test.policy, test.set, err)
+			continue
+		}
+		if out != test.out {
+			t.Errorf("%q (%q, %t): want %q, got %q", test.name, test.policy, test.set, test.out, out)
+		}
+	}
+
+	if _, err := machineHostname("node1", "long", false); err == nil {
+		t.Error("expected an error for an unknown policy")
+	}
+}
+
+func TestTemplateFlag(t *testing.T) {
+	var templates templateFlag
+	for _, value := range []string{"/etc/etcd.tmpl:/run/etcd.env", "a.tmpl:/c:d"} {
+		if err := templates.Set(value); err != nil {
+			t.Fatalf("%q: unexpected error: %v", value, err)
+		}
+	}
+	expected := templateFlag{{"/etc/etcd.tmpl", "/run/etcd.env"}, {"a.tmpl", "/c:d"}}
+	if !reflect.DeepEqual(templates, expected) {
+		t.Errorf("bad templates: want %v, got %v", expected, templates)
+	}
+
+	for _, value := range []string{"a.tmpl", ":dest", "src:"} {
+		if err := templates.Set(value); err == nil {
+			t.Errorf("%q: expected an error", value)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mount
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// ByLabel mounts the first filesystem found with one of the given labels
+// read-only in a temporary directory, trying each of the filesystem types in
+// turn. It returns that directory along with a function to unmount it.
+func ByLabel(labels []string, filesystems []string) (string, func(), error) {
+	var device string
+	for _, label := range labels {
+		path := filepath.Join("/dev/disk/by-label", label)
+		if _, err := os.Stat(path); err == nil {
+			device = path
+			break
+		}
+	}
+	if device == "" {
+		return "", nil, fmt.Errorf("no filesystem labelled %q found", labels[0])
+	}
+
+	dir, err := ioutil.TempDir("", "coreos-metadata")
+	if err != nil {
+		return "", nil, err
+	}
+
+	for _, fs := range filesystems {
+		if err = syscall.Mount(device, dir, fs, syscall.MS_RDONLY, ""); err == nil {
+			return dir, func() {
+				syscall.Unmount(dir, 0)
+				os.Remove(dir)
+			}, nil
+		}
+	}
+
+	os.Remove(dir)
+	return "", nil, fmt.Errorf("failed to mount %q: %v", device, err)
+}
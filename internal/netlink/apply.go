@@ -0,0 +1,219 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netlink
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+
+	"github.com/coreos/coreos-metadata/internal/providers"
+)
+
+// State is the network configuration of the kernel.
+type State struct {
+	Links  []Link
+	Addrs  []Addr
+	Routes []Route
+}
+
+// Change is a single modification of the kernel's network configuration.
+// Its string form is a line of a diff against the current configuration.
+type Change struct {
+	desc  string
+	apply func() error
+}
+
+func (c Change) String() string {
+	return c.desc
+}
+
+func CurrentState() (State, error) {
+	links, err := Links()
+	if err != nil {
+		return State{}, err
+	}
+	addrs, err := Addrs()
+	if err != nil {
+		return State{}, err
+	}
+	routes, err := Routes()
+	if err != nil {
+		return State{}, err
+	}
+
+	return State{Links: links, Addrs: addrs, Routes: routes}, nil
+}
+
+// Plan returns the changes which bring the links described by ifaces in
+// state in line with them. Links are brought up and their MTU set, and
+// their static addresses and routes are replaced. Addresses and routes
+// obtained dynamically are left alone, as are links which are not
+// described. Virtual devices cannot be created and are rejected.
+func Plan(state State, ifaces []providers.NetworkInterface) ([]Change, error) {
+	var links, delAddrs, addAddrs, delRoutes, addRoutes []Change
+
+	for _, iface := range ifaces {
+		if iface.Kind != providers.Physical {
+			return nil, fmt.Errorf("cannot create %s device %q", iface.Kind, iface.Name)
+		}
+		link, err := findLink(state.Links, iface)
+		if err != nil {
+			return nil, err
+		}
+		name := link.Name
+
+		if !link.Up || (iface.MTU > 0 && iface.MTU != link.MTU) {
+			desc := fmt.Sprintf("~ %s: up", name)
+			mtu := 0
+			if iface.MTU > 0 && iface.MTU != link.MTU {
+				desc = fmt.Sprintf("~ %s: up, mtu %d -> %d", name, link.MTU, iface.MTU)
+				mtu = iface.MTU
+			}
+			index := link.Index
+			links = append(links, Change{desc, func() error { return SetLink(index, mtu) }})
+		}
+
+		wanted := map[string]bool{}
+		for _, ipnet := range iface.IPAddresses {
+			wanted[ipnet.String()] = true
+		}
+		present := map[string]bool{}
+		for _, addr := range state.Addrs {
+			if addr.LinkIndex != link.Index {
+				continue
+			}
+			present[addr.IPNet.String()] = true
+			if wanted[addr.IPNet.String()] || !addr.Permanent || addr.LinkLocal {
+				continue
+			}
+			addr := addr
+			desc := fmt.Sprintf("- %s: address %s", name, addr.IPNet.String())
+			delAddrs = append(delAddrs, Change{desc, func() error { return DelAddr(addr) }})
+		}
+		for _, ipnet := range iface.IPAddresses {
+			if present[ipnet.String()] {
+				continue
+			}
+			addr := Addr{LinkIndex: link.Index, IPNet: ipnet, Permanent: true}
+			desc := fmt.Sprintf("+ %s: address %s", name, ipnet.String())
+			addAddrs = append(addAddrs, Change{desc, func() error { return AddAddr(addr) }})
+		}
+
+		wanted = map[string]bool{}
+		var routes []Route
+		for _, r := range iface.Routes {
+			route := Route{
+				LinkIndex:   link.Index,
+				Destination: r.Destination,
+				Gateway:     r.Gateway,
+				Metric:      r.Metric,
+				Scope:       r.Scope,
+				OnLink:      r.OnLink,
+				Static:      true,
+			}
+			routes = append(routes, route)
+			wanted[routeKey(route)] = true
+		}
+		present = map[string]bool{}
+		for _, route := range state.Routes {
+			if route.LinkIndex != link.Index {
+				continue
+			}
+			present[routeKey(route)] = true
+			if wanted[routeKey(route)] || !route.Static {
+				continue
+			}
+			route := route
+			desc := fmt.Sprintf("- %s: %s", name, describeRoute(route))
+			delRoutes = append(delRoutes, Change{desc, func() error { return DelRoute(route) }})
+		}
+		for _, route := range routes {
+			if present[routeKey(route)] {
+				continue
+			}
+			route := route
+			desc := fmt.Sprintf("+ %s: %s", name, describeRoute(route))
+			addRoutes = append(addRoutes, Change{desc, func() error { return AddRoute(route) }})
+		}
+	}
+
+	// Routes are removed before the addresses their gateways are reached
+	// through, which would take the routes with them, and added after the
+	// addresses they need.
+	changes := append(links, delRoutes...)
+	changes = append(changes, delAddrs...)
+	changes = append(changes, addAddrs...)
+	return append(changes, addRoutes...), nil
+}
+
+// Apply makes the changes in order, stopping at the first which fails.
+func Apply(changes []Change) error {
+	for _, change := range changes {
+		if err := change.apply(); err != nil {
+			return fmt.Errorf("%s: %v", change, err)
+		}
+	}
+	return nil
+}
+
+func findLink(links []Link, iface providers.NetworkInterface) (Link, error) {
+	for _, link := range links {
+		if iface.HardwareAddress != nil {
+			if bytes.Equal(link.HardwareAddress, iface.HardwareAddress) {
+				return link, nil
+			}
+		} else if link.Name == iface.Name {
+			return link, nil
+		}
+	}
+
+	if iface.HardwareAddress != nil {
+		return Link{}, fmt.Errorf("no link with address %s", iface.HardwareAddress)
+	}
+	return Link{}, fmt.Errorf("no link named %q", iface.Name)
+}
+
+// routeKey identifies a route by the attributes which the kernel uses to
+// tell routes apart.
+func routeKey(route Route) string {
+	metric := route.Metric
+	if metric == 0 && route.Destination.IP.To4() == nil {
+		// the kernel's default metric for IPv6 routes
+		metric = 1024
+	}
+	return fmt.Sprintf("%s %s %d", route.Destination.String(), gatewayString(route.Gateway), metric)
+}
+
+func describeRoute(route Route) string {
+	desc := "route " + route.Destination.String()
+	if route.Gateway != nil {
+		desc += " via " + route.Gateway.String()
+	}
+	if route.Metric > 0 {
+		desc += fmt.Sprintf(" metric %d", route.Metric)
+	}
+	if route.OnLink {
+		desc += " onlink"
+	}
+	return desc
+}
+
+func gatewayString(gw net.IP) string {
+	if gw == nil {
+		return ""
+	}
+	return gw.String()
+}
@@ -0,0 +1,98 @@
+package netlink
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/coreos/coreos-metadata/internal/providers"
+)
+
+func mustParseCIDR(s string) net.IPNet {
+	ip, n, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	return net.IPNet{IP: ip, Mask: n.Mask}
+}
+
+func TestPlan(t *testing.T) {
+	mac, _ := net.ParseMAC("52:54:00:12:34:00")
+	state := State{
+		Links: []Link{
+			{Index: 1, Name: "lo", MTU: 65536, Up: true},
+			{Index: 2, Name: "eth0", HardwareAddress: mac, MTU: 1500},
+		},
+		Addrs: []Addr{
+			{LinkIndex: 1, IPNet: mustParseCIDR("127.0.0.1/8"), Permanent: true},
+			{LinkIndex: 2, IPNet: mustParseCIDR("10.0.0.9/24"), Permanent: true},
+			{LinkIndex: 2, IPNet: mustParseCIDR("10.0.0.5/24"), Permanent: true},
+			{LinkIndex: 2, IPNet: mustParseCIDR("fe80::1/64"), Permanent: true, LinkLocal: true},
+			{LinkIndex: 2, IPNet: mustParseCIDR("2001:db8::9/64")},
+		},
+		Routes: []Route{
+			{LinkIndex: 2, Destination: mustParseCIDR("10.0.0.0/24"), Scope: "link"},
+			{LinkIndex: 2, Destination: mustParseCIDR("10.2.0.0/16"), Gateway: net.ParseIP("10.0.0.2").To4(), Static: true},
+			{LinkIndex: 2, Destination: mustParseCIDR("2001:db8:1::/48"), Gateway: net.ParseIP("fe80::2"), Metric: 1024, Static: true},
+		},
+	}
+
+	changes, err := Plan(state, []providers.NetworkInterface{{
+		HardwareAddress: mac,
+		MTU:             9000,
+		IPAddresses: []net.IPNet{
+			mustParseCIDR("10.0.0.5/24"),
+			mustParseCIDR("10.0.0.6/24"),
+		},
+		Routes: []providers.NetworkRoute{
+			{Destination: mustParseCIDR("0.0.0.0/0"), Gateway: net.ParseIP("10.0.0.1").To4()},
+			{Destination: mustParseCIDR("2001:db8:1::/48"), Gateway: net.ParseIP("fe80::2")},
+		},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var diff []string
+	for _, change := range changes {
+		diff = append(diff, change.String())
+	}
+	expected := []string{
+		"~ eth0: up, mtu 1500 -> 9000",
+		"- eth0: route 10.2.0.0/16 via 10.0.0.2",
+		"- eth0: address 10.0.0.9/24",
+		"+ eth0: address 10.0.0.6/24",
+		"+ eth0: route 0.0.0.0/0 via 10.0.0.1",
+	}
+	if !reflect.DeepEqual(diff, expected) {
+		t.Errorf("bad changes:\nwant %q\ngot  %q", expected, diff)
+	}
+}
+
+func TestPlanErrors(t *testing.T) {
+	mac, _ := net.ParseMAC("52:54:00:12:34:00")
+	state := State{Links: []Link{{Index: 2, Name: "eth0"}}}
+
+	tests := []struct {
+		desc  string
+		iface providers.NetworkInterface
+	}{
+		{
+			desc:  "missing link",
+			iface: providers.NetworkInterface{HardwareAddress: mac},
+		},
+		{
+			desc:  "virtual device",
+			iface: providers.NetworkInterface{Name: "bond0", Kind: providers.Bond},
+		},
+	}
+
+	for _, test := range tests {
+		if _, err := Plan(state, []providers.NetworkInterface{test.iface}); err == nil {
+			t.Errorf("%s: expected an error", test.desc)
+		}
+	}
+}
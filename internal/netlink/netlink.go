@@ -0,0 +1,412 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package netlink reads and programs the links, addresses and routes of the
+// kernel through rtnetlink.
+package netlink
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	rtprotStatic = 4
+	rtnhFOnlink  = 4
+	ifaFPerm     = 0x80
+	rtaTable     = 15
+
+	scopeUniverse = 0
+	scopeLink     = 253
+	scopeHost     = 254
+	scopeNowhere  = 255
+)
+
+// native is the byte order of netlink messages, which is that of the host.
+var native binary.ByteOrder = func() binary.ByteOrder {
+	x := uint16(1)
+	if *(*byte)(unsafe.Pointer(&x)) == 1 {
+		return binary.LittleEndian
+	}
+	return binary.BigEndian
+}()
+
+type Link struct {
+	Index           int
+	Name            string
+	HardwareAddress net.HardwareAddr
+	MTU             int
+	Up              bool
+}
+
+type Addr struct {
+	LinkIndex int
+	IPNet     net.IPNet
+	// Permanent is false for addresses with a lifetime, i.e. those which
+	// were obtained through DHCP or SLAAC.
+	Permanent bool
+	LinkLocal bool
+}
+
+// Route is a unicast route in the main routing table.
+type Route struct {
+	LinkIndex   int
+	Destination net.IPNet
+	Gateway     net.IP
+	Metric      int
+	Scope       string
+	OnLink      bool
+	// Static is set for routes added with the static protocol, as Apply
+	// does, as opposed to those of the kernel, DHCP or router
+	// advertisements.
+	Static bool
+}
+
+// Links returns all of the network links.
+func Links() ([]Link, error) {
+	msgs, err := dump(syscall.RTM_GETLINK)
+	if err != nil {
+		return nil, err
+	}
+
+	var links []Link
+	for _, msg := range msgs {
+		if msg.Header.Type != syscall.RTM_NEWLINK || len(msg.Data) < syscall.SizeofIfInfomsg {
+			continue
+		}
+		attrs, err := syscall.ParseNetlinkRouteAttr(&msg)
+		if err != nil {
+			return nil, err
+		}
+
+		link := Link{
+			Index: int(int32(native.Uint32(msg.Data[4:8]))),
+			Up:    native.Uint32(msg.Data[8:12])&syscall.IFF_UP != 0,
+		}
+		for _, attr := range attrs {
+			switch attr.Attr.Type {
+			case syscall.IFLA_IFNAME:
+				link.Name = string(trimNull(attr.Value))
+			case syscall.IFLA_ADDRESS:
+				link.HardwareAddress = net.HardwareAddr(append([]byte{}, attr.Value...))
+			case syscall.IFLA_MTU:
+				link.MTU = int(native.Uint32(attr.Value))
+			}
+		}
+		links = append(links, link)
+	}
+
+	return links, nil
+}
+
+// Addrs returns the addresses of all links.
+func Addrs() ([]Addr, error) {
+	msgs, err := dump(syscall.RTM_GETADDR)
+	if err != nil {
+		return nil, err
+	}
+
+	var addrs []Addr
+	for _, msg := range msgs {
+		if msg.Header.Type != syscall.RTM_NEWADDR || len(msg.Data) < syscall.SizeofIfAddrmsg {
+			continue
+		}
+		attrs, err := syscall.ParseNetlinkRouteAttr(&msg)
+		if err != nil {
+			return nil, err
+		}
+
+		family, prefix := msg.Data[0], int(msg.Data[1])
+		flags := uint32(msg.Data[2])
+		addr := Addr{
+			LinkIndex: int(native.Uint32(msg.Data[4:8])),
+			LinkLocal: msg.Data[3] == scopeLink,
+		}
+
+		var local, address net.IP
+		for _, attr := range attrs {
+			switch attr.Attr.Type {
+			case syscall.IFA_LOCAL:
+				local = net.IP(append([]byte{}, attr.Value...))
+			case syscall.IFA_ADDRESS:
+				address = net.IP(append([]byte{}, attr.Value...))
+			case 8: // IFA_FLAGS supersedes the 8-bit flags in the header
+				flags = native.Uint32(attr.Value)
+			}
+		}
+		if local == nil {
+			local = address
+		}
+		if local == nil {
+			continue
+		}
+
+		bits := 8 * net.IPv6len
+		if family == syscall.AF_INET {
+			bits = 8 * net.IPv4len
+		}
+		addr.IPNet = net.IPNet{IP: local, Mask: net.CIDRMask(prefix, bits)}
+		addr.Permanent = flags&ifaFPerm != 0
+		addrs = append(addrs, addr)
+	}
+
+	return addrs, nil
+}
+
+// Routes returns the unicast routes of the main routing table.
+func Routes() ([]Route, error) {
+	msgs, err := dump(syscall.RTM_GETROUTE)
+	if err != nil {
+		return nil, err
+	}
+
+	var routes []Route
+	for _, msg := range msgs {
+		if msg.Header.Type != syscall.RTM_NEWROUTE || len(msg.Data) < syscall.SizeofRtMsg {
+			continue
+		}
+		attrs, err := syscall.ParseNetlinkRouteAttr(&msg)
+		if err != nil {
+			return nil, err
+		}
+
+		family, prefix := msg.Data[0], int(msg.Data[1])
+		table := uint32(msg.Data[4])
+		if msg.Data[7] != syscall.RTN_UNICAST {
+			continue
+		}
+
+		bits, ip := 8*net.IPv6len, net.IP(make([]byte, net.IPv6len))
+		if family == syscall.AF_INET {
+			bits, ip = 8*net.IPv4len, net.IP(make([]byte, net.IPv4len))
+		}
+		route := Route{
+			Destination: net.IPNet{IP: ip, Mask: net.CIDRMask(prefix, bits)},
+			Scope:       scopeName(msg.Data[6]),
+			OnLink:      native.Uint32(msg.Data[8:12])&rtnhFOnlink != 0,
+			Static:      msg.Data[5] == rtprotStatic,
+		}
+		for _, attr := range attrs {
+			switch attr.Attr.Type {
+			case rtaTable:
+				table = native.Uint32(attr.Value)
+			case syscall.RTA_DST:
+				route.Destination.IP = net.IP(append([]byte{}, attr.Value...))
+			case syscall.RTA_GATEWAY:
+				route.Gateway = net.IP(append([]byte{}, attr.Value...))
+			case syscall.RTA_OIF:
+				route.LinkIndex = int(native.Uint32(attr.Value))
+			case syscall.RTA_PRIORITY:
+				route.Metric = int(native.Uint32(attr.Value))
+			}
+		}
+		if table != syscall.RT_TABLE_MAIN {
+			continue
+		}
+		routes = append(routes, route)
+	}
+
+	return routes, nil
+}
+
+// SetLink brings the link up and, if mtu is positive, sets its MTU.
+func SetLink(index int, mtu int) error {
+	msg := make([]byte, syscall.SizeofIfInfomsg)
+	native.PutUint32(msg[4:8], uint32(index))
+	native.PutUint32(msg[8:12], syscall.IFF_UP)
+	native.PutUint32(msg[12:16], syscall.IFF_UP)
+	if mtu > 0 {
+		msg = appendAttr(msg, syscall.IFLA_MTU, uint32Bytes(uint32(mtu)))
+	}
+
+	return request(syscall.RTM_NEWLINK, 0, msg)
+}
+
+func AddAddr(addr Addr) error {
+	return request(syscall.RTM_NEWADDR, syscall.NLM_F_CREATE|syscall.NLM_F_EXCL, addrMessage(addr))
+}
+
+func DelAddr(addr Addr) error {
+	return request(syscall.RTM_DELADDR, 0, addrMessage(addr))
+}
+
+func AddRoute(route Route) error {
+	return request(syscall.RTM_NEWROUTE, syscall.NLM_F_CREATE|syscall.NLM_F_EXCL, routeMessage(route, false))
+}
+
+func DelRoute(route Route) error {
+	return request(syscall.RTM_DELROUTE, 0, routeMessage(route, true))
+}
+
+func addrMessage(addr Addr) []byte {
+	family, ip := ipFamily(addr.IPNet.IP)
+	prefix, _ := addr.IPNet.Mask.Size()
+
+	msg := make([]byte, syscall.SizeofIfAddrmsg)
+	msg[0] = family
+	msg[1] = byte(prefix)
+	native.PutUint32(msg[4:8], uint32(addr.LinkIndex))
+	msg = appendAttr(msg, syscall.IFA_LOCAL, ip)
+	msg = appendAttr(msg, syscall.IFA_ADDRESS, ip)
+	return msg
+}
+
+func routeMessage(route Route, del bool) []byte {
+	family, dest := ipFamily(route.Destination.IP)
+	prefix, _ := route.Destination.Mask.Size()
+
+	msg := make([]byte, syscall.SizeofRtMsg)
+	msg[0] = family
+	msg[1] = byte(prefix)
+	msg[4] = syscall.RT_TABLE_MAIN
+	msg[5] = rtprotStatic
+	msg[7] = syscall.RTN_UNICAST
+	switch {
+	case del:
+		msg[6] = scopeNowhere
+	case route.Scope != "":
+		msg[6] = scopeValue(route.Scope)
+	case route.Gateway == nil:
+		msg[6] = scopeLink
+	default:
+		msg[6] = scopeUniverse
+	}
+	if route.OnLink {
+		native.PutUint32(msg[8:12], rtnhFOnlink)
+	}
+
+	msg = appendAttr(msg, syscall.RTA_DST, dest)
+	if route.Gateway != nil {
+		_, gw := ipFamily(route.Gateway)
+		msg = appendAttr(msg, syscall.RTA_GATEWAY, gw)
+	}
+	msg = appendAttr(msg, syscall.RTA_OIF, uint32Bytes(uint32(route.LinkIndex)))
+	if route.Metric > 0 {
+		msg = appendAttr(msg, syscall.RTA_PRIORITY, uint32Bytes(uint32(route.Metric)))
+	}
+	return msg
+}
+
+// dump returns the messages of an rtnetlink dump request of the given type.
+func dump(typ int) ([]syscall.NetlinkMessage, error) {
+	rib, err := syscall.NetlinkRIB(typ, syscall.AF_UNSPEC)
+	if err != nil {
+		return nil, fmt.Errorf("netlink dump: %v", err)
+	}
+	return syscall.ParseNetlinkMessage(rib)
+}
+
+// request sends an rtnetlink request and waits for its acknowledgement.
+func request(typ uint16, flags uint16, body []byte) error {
+	fd, err := syscall.Socket(syscall.AF_NETLINK, syscall.SOCK_RAW|syscall.SOCK_CLOEXEC, syscall.NETLINK_ROUTE)
+	if err != nil {
+		return err
+	}
+	defer syscall.Close(fd)
+
+	sa := &syscall.SockaddrNetlink{Family: syscall.AF_NETLINK}
+	if err := syscall.Bind(fd, sa); err != nil {
+		return err
+	}
+
+	msg := make([]byte, syscall.NLMSG_HDRLEN, syscall.NLMSG_HDRLEN+len(body))
+	native.PutUint32(msg[0:4], uint32(syscall.NLMSG_HDRLEN+len(body)))
+	native.PutUint16(msg[4:6], typ)
+	native.PutUint16(msg[6:8], syscall.NLM_F_REQUEST|syscall.NLM_F_ACK|flags)
+	native.PutUint32(msg[8:12], 1)
+	msg = append(msg, body...)
+	if err := syscall.Sendto(fd, msg, 0, sa); err != nil {
+		return err
+	}
+
+	buf := make([]byte, syscall.Getpagesize())
+	for {
+		n, _, err := syscall.Recvfrom(fd, buf, 0)
+		if err != nil {
+			return err
+		}
+		replies, err := syscall.ParseNetlinkMessage(buf[:n])
+		if err != nil {
+			return err
+		}
+		for _, reply := range replies {
+			if reply.Header.Seq != 1 || reply.Header.Type != syscall.NLMSG_ERROR {
+				continue
+			}
+			if len(reply.Data) < 4 {
+				return fmt.Errorf("short netlink acknowledgement")
+			}
+			if errno := int32(native.Uint32(reply.Data[0:4])); errno != 0 {
+				return syscall.Errno(-errno)
+			}
+			return nil
+		}
+	}
+}
+
+func appendAttr(msg []byte, typ uint16, value []byte) []byte {
+	length := syscall.SizeofRtAttr + len(value)
+	attr := make([]byte, (length+syscall.RTA_ALIGNTO-1) & ^(syscall.RTA_ALIGNTO-1))
+	native.PutUint16(attr[0:2], uint16(length))
+	native.PutUint16(attr[2:4], typ)
+	copy(attr[4:], value)
+	return append(msg, attr...)
+}
+
+func ipFamily(ip net.IP) (byte, net.IP) {
+	if ip4 := ip.To4(); ip4 != nil {
+		return syscall.AF_INET, ip4
+	}
+	return syscall.AF_INET6, ip.To16()
+}
+
+func uint32Bytes(v uint32) []byte {
+	b := make([]byte, 4)
+	native.PutUint32(b, v)
+	return b
+}
+
+func trimNull(b []byte) []byte {
+	for i, c := range b {
+		if c == 0 {
+			return b[:i]
+		}
+	}
+	return b
+}
+
+func scopeName(scope byte) string {
+	switch scope {
+	case scopeLink:
+		return "link"
+	case scopeHost:
+		return "host"
+	default:
+		return "global"
+	}
+}
+
+func scopeValue(scope string) byte {
+	switch scope {
+	case "link":
+		return scopeLink
+	case "host":
+		return scopeHost
+	default:
+		return scopeUniverse
+	}
+}
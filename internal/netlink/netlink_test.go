@@ -0,0 +1,116 @@
+package netlink
+
+import (
+	"net"
+	"os"
+	"os/exec"
+	"runtime"
+	"syscall"
+	"testing"
+
+	"github.com/coreos/coreos-metadata/internal/providers"
+)
+
+// enterNetns moves the test into a fresh network namespace containing a
+// link named test0, skipping the test if one cannot be created. The test's
+// thread is left in the namespace and exits along with the test.
+func enterNetns(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("network namespaces require root")
+	}
+	if _, err := exec.LookPath("ip"); err != nil {
+		t.Skip("ip not found")
+	}
+
+	runtime.LockOSThread()
+	if err := syscall.Unshare(syscall.CLONE_NEWNET); err != nil {
+		t.Skipf("cannot create network namespace: %v", err)
+	}
+
+	// dummy links need a kernel module which may be missing, so fall back
+	// to one end of a veth pair
+	out, err := exec.Command("ip", "link", "add", "test0", "type", "dummy").CombinedOutput()
+	if err != nil {
+		out, err = exec.Command("ip", "link", "add", "test0", "type", "veth", "peer", "name", "test1").CombinedOutput()
+	}
+	if err != nil {
+		t.Skipf("cannot create test link: %v: %s", err, out)
+	}
+}
+
+func TestApply(t *testing.T) {
+	enterNetns(t)
+
+	ifaces := []providers.NetworkInterface{{
+		Name:        "test0",
+		MTU:         1400,
+		IPAddresses: []net.IPNet{mustParseCIDR("10.0.0.5/24")},
+		Routes: []providers.NetworkRoute{
+			{Destination: mustParseCIDR("10.1.0.0/16"), Gateway: net.ParseIP("10.0.0.1").To4(), Metric: 10},
+			{Destination: mustParseCIDR("0.0.0.0/0"), Gateway: net.ParseIP("192.0.2.1").To4(), OnLink: true},
+		},
+	}}
+
+	for i := 0; i < 2; i++ {
+		state, err := CurrentState()
+		if err != nil {
+			t.Fatal(err)
+		}
+		changes, err := Plan(state, ifaces)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if i == 1 {
+			if len(changes) != 0 {
+				t.Fatalf("changes remain after applying: %v", changes)
+			}
+			break
+		}
+		if len(changes) != 4 {
+			t.Fatalf("expected 4 changes, got %v", changes)
+		}
+		if err := Apply(changes); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the address and routes are replaced
+	ifaces[0].IPAddresses = []net.IPNet{mustParseCIDR("10.0.0.6/24")}
+	ifaces[0].Routes = nil
+	state, err := CurrentState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes, err := Plan(state, ifaces)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Apply(changes); err != nil {
+		t.Fatal(err)
+	}
+
+	addrs, err := Addrs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found []string
+	for _, addr := range addrs {
+		if addr.IPNet.IP.To4() != nil && !addr.IPNet.IP.IsLoopback() {
+			found = append(found, addr.IPNet.String())
+		}
+	}
+	if len(found) != 1 || found[0] != "10.0.0.6/24" {
+		t.Errorf("bad addresses after update: %v", found)
+	}
+
+	routes, err := Routes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, route := range routes {
+		if route.Static {
+			t.Errorf("static route remains after update: %s", describeRoute(route))
+		}
+	}
+}
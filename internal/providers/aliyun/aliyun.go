@@ -0,0 +1,138 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aliyun
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/coreos/coreos-metadata/internal/providers"
+	"github.com/coreos/coreos-metadata/internal/retry"
+)
+
+const (
+	// TokenTTL is the lifetime requested for the metadata access token
+	// used in the hardened (token-based) access mode.
+	TokenTTL = 6 * time.Hour
+
+	// tokenAttempts bounds how long a token is waited for before falling
+	// back to the normal (tokenless) access mode.
+	tokenAttempts = 3
+)
+
+var (
+	metadataEndpoint = "http://100.100.100.200/latest/"
+	initialBackoff   = time.Second
+)
+
+// Schema describes the attributes produced by FetchMetadata.
+var Schema = providers.Schema{
+	{Name: "ALIYUN_HOSTNAME", Description: "The hostname of the instance", Type: providers.TypeString, Optional: true},
+	{Name: "ALIYUN_INSTANCE_ID", Description: "The ID of the instance", Type: providers.TypeString, Optional: true},
+	{Name: "ALIYUN_REGION", Description: "The region of the instance", Type: providers.TypeString, Optional: true},
+	{Name: "ALIYUN_ZONE", Description: "The zone of the instance", Type: providers.TypeString, Optional: true},
+	{Name: "ALIYUN_IPV4_PRIVATE", Description: "The private IPv4 address", Type: providers.TypeIPv4, Optional: true},
+	{Name: "ALIYUN_IPV4_PUBLIC", Description: "The public IPv4 address", Type: providers.TypeIPv4, Optional: true},
+	{Name: "ALIYUN_IPV4_EIP", Description: "The elastic IPv4 address", Type: providers.TypeIPv4, Optional: true},
+}
+
+func FetchMetadata() (providers.Metadata, error) {
+	token, err := fetchToken()
+	if err != nil {
+		fmt.Printf("Failed to fetch metadata token, using the normal access mode: %v\n", err)
+		token = ""
+	}
+	fetch := fetcher(token)
+
+	m := providers.Metadata{}
+	m.Attributes = make(map[string]string)
+
+	for key, attrKey := range map[string]string{
+		"meta-data/instance-id":  "ALIYUN_INSTANCE_ID",
+		"meta-data/region-id":    "ALIYUN_REGION",
+		"meta-data/zone-id":      "ALIYUN_ZONE",
+		"meta-data/hostname":     "ALIYUN_HOSTNAME",
+		"meta-data/private-ipv4": "ALIYUN_IPV4_PRIVATE",
+		"meta-data/public-ipv4":  "ALIYUN_IPV4_PUBLIC",
+		"meta-data/eipv4":        "ALIYUN_IPV4_EIP",
+	} {
+		val, ok, err := fetch(key)
+		if err != nil {
+			return providers.Metadata{}, err
+		}
+		if ok && val != "" {
+			m.Attributes[attrKey] = val
+		}
+	}
+	m.Hostname = m.Attributes["ALIYUN_HOSTNAME"]
+	m.Common = providers.Common{
+		PrivateIPv4: net.ParseIP(m.Attributes["ALIYUN_IPV4_PRIVATE"]),
+		PublicIPv4:  net.ParseIP(m.Attributes["ALIYUN_IPV4_PUBLIC"]),
+		Region:      m.Attributes["ALIYUN_REGION"],
+		Zone:        m.Attributes["ALIYUN_ZONE"],
+		InstanceID:  m.Attributes["ALIYUN_INSTANCE_ID"],
+	}
+	if m.Common.PublicIPv4 == nil {
+		m.Common.PublicIPv4 = net.ParseIP(m.Attributes["ALIYUN_IPV4_EIP"])
+	}
+
+	keys, err := providers.FetchEC2Keys(fetch, "meta-data")
+	if err != nil {
+		return providers.Metadata{}, err
+	}
+	m.SshKeys = keys
+
+	return m, nil
+}
+
+// fetchToken requests a token for the hardened access mode, which is
+// unavailable when the mode is disabled or PUT requests are blocked.
+func fetchToken() (string, error) {
+	body, err := retry.Client{
+		InitialBackoff: initialBackoff,
+		MaxBackoff:     time.Second * 5,
+		MaxAttempts:    tokenAttempts,
+		Header: map[string][]string{
+			"X-aliyun-ecs-metadata-token-ttl-seconds": {fmt.Sprint(int(TokenTTL.Seconds()))},
+		},
+	}.Put(metadataEndpoint+"api/token", nil)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(body)), nil
+}
+
+// fetcher returns a function fetching metadata keys, with the token if one
+// was obtained.
+func fetcher(token string) func(key string) (string, bool, error) {
+	client := retry.Client{
+		InitialBackoff: initialBackoff,
+		MaxBackoff:     time.Second * 5,
+		MaxAttempts:    10,
+	}
+	if token != "" {
+		client.Header = map[string][]string{
+			"X-aliyun-ecs-metadata-token": {token},
+		}
+	}
+
+	return func(key string) (string, bool, error) {
+		body, err := client.Get(metadataEndpoint + key)
+		return strings.TrimSpace(string(body)), (body != nil), err
+	}
+}
@@ -0,0 +1,98 @@
+package aliyun
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/coreos/coreos-metadata/internal/providers"
+)
+
+var testMetadata = map[string]string{
+	"/latest/meta-data/instance-id":               "i-bp67acfmxazb4p",
+	"/latest/meta-data/region-id":                 "cn-hangzhou",
+	"/latest/meta-data/zone-id":                   "cn-hangzhou-i",
+	"/latest/meta-data/hostname":                  "node1",
+	"/latest/meta-data/private-ipv4":              "192.168.0.10",
+	"/latest/meta-data/eipv4":                     "203.0.113.10",
+	"/latest/meta-data/public-keys":               "0/",
+	"/latest/meta-data/public-keys/0/openssh-key": "ssh-rsa AAAA core@example",
+}
+
+func TestFetchMetadata(t *testing.T) {
+	defer func(endpoint string, backoff time.Duration) {
+		metadataEndpoint = endpoint
+		initialBackoff = backoff
+	}(metadataEndpoint, initialBackoff)
+	initialBackoff = time.Millisecond
+
+	want := providers.Metadata{
+		Attributes: map[string]string{
+			"ALIYUN_INSTANCE_ID":  "i-bp67acfmxazb4p",
+			"ALIYUN_REGION":       "cn-hangzhou",
+			"ALIYUN_ZONE":         "cn-hangzhou-i",
+			"ALIYUN_HOSTNAME":     "node1",
+			"ALIYUN_IPV4_PRIVATE": "192.168.0.10",
+			"ALIYUN_IPV4_EIP":     "203.0.113.10",
+		},
+		Hostname: "node1",
+		SshKeys:  []string{"ssh-rsa AAAA core@example"},
+		Common: providers.Common{
+			PrivateIPv4: net.ParseIP("192.168.0.10"),
+			PublicIPv4:  net.ParseIP("203.0.113.10"),
+			Region:      "cn-hangzhou",
+			Zone:        "cn-hangzhou-i",
+			InstanceID:  "i-bp67acfmxazb4p",
+		},
+	}
+
+	tests := []struct {
+		desc     string
+		hardened bool
+	}{
+		{desc: "hardened mode", hardened: true},
+		{desc: "normal mode", hardened: false},
+	}
+
+	for _, tt := range tests {
+		hardened := tt.hardened
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/latest/api/token" {
+				if !hardened || r.Method != "PUT" {
+					http.Error(w, "", http.StatusForbidden)
+					return
+				}
+				w.Write([]byte("token\n"))
+				return
+			}
+
+			if token := r.Header.Get("X-aliyun-ecs-metadata-token"); hardened != (token == "token") {
+				http.Error(w, "", http.StatusUnauthorized)
+				return
+			}
+			value, ok := testMetadata[r.URL.Path]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write([]byte(value))
+		}))
+		metadataEndpoint = server.URL + "/latest/"
+
+		got, err := FetchMetadata()
+		server.Close()
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.desc, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s:\nwant: %#v\n got: %#v", tt.desc, want, got)
+		}
+		if err := Schema.Validate(got.Attributes); err != nil {
+			t.Errorf("%s: %v", tt.desc, err)
+		}
+	}
+}
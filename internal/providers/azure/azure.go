@@ -15,15 +15,13 @@
 package azure
 
 import (
-	"bufio"
 	"encoding/xml"
 	"fmt"
 	"net"
-	"os"
 	"strconv"
-	"strings"
 	"time"
 
+	"github.com/coreos/coreos-metadata/internal/lease"
 	"github.com/coreos/coreos-metadata/internal/providers"
 	"github.com/coreos/coreos-metadata/internal/retry"
 )
@@ -31,7 +29,6 @@ import (
 const (
 	AgentName             = "com.coreos.metadata"
 	FabricProtocolVersion = "2012-11-30"
-	LeaseRetryInterval    = 500 * time.Millisecond
 )
 
 type metadata struct {
@@ -39,6 +36,12 @@ type metadata struct {
 	dynamicIPv4 net.IP
 }
 
+// Schema describes the attributes produced by FetchMetadata.
+var Schema = providers.Schema{
+	{Name: "AZURE_IPV4_DYNAMIC", Description: "The private IPv4 address", Type: providers.TypeIPv4, Optional: true},
+	{Name: "AZURE_IPV4_VIRTUAL", Description: "The public virtual IPv4 address", Type: providers.TypeIPv4, Optional: true},
+}
+
 func FetchMetadata() (providers.Metadata, error) {
 	addr, err := getFabricAddress()
 	if err != nil {
@@ -59,6 +62,10 @@ func FetchMetadata() (providers.Metadata, error) {
 			"AZURE_IPV4_DYNAMIC": providers.String(config.dynamicIPv4),
 			"AZURE_IPV4_VIRTUAL": providers.String(config.virtualIPv4),
 		},
+		Common: providers.Common{
+			PrivateIPv4: config.dynamicIPv4,
+			PublicIPv4:  config.virtualIPv4,
+		},
 	}, nil
 }
 
@@ -77,45 +84,11 @@ func getClient() retry.Client {
 	return client
 }
 
-func findLease() (*os.File, error) {
-	ifaces, err := net.Interfaces()
-	if err != nil {
-		return nil, fmt.Errorf("could not list interfaces: %v", err)
-	}
-
-	for {
-		for _, iface := range ifaces {
-			lease, err := os.Open(fmt.Sprintf("/run/systemd/netif/leases/%d", iface.Index))
-			if os.IsNotExist(err) {
-				continue
-			} else if err != nil {
-				return nil, err
-			} else {
-				return lease, nil
-			}
-		}
-
-		fmt.Printf("No leases found. Waiting...")
-		time.Sleep(LeaseRetryInterval)
-	}
-}
-
 func getFabricAddress() (net.IP, error) {
-	lease, err := findLease()
+	rawEndpoint, err := lease.GetOption("OPTION_245")
 	if err != nil {
 		return nil, err
 	}
-	defer lease.Close()
-
-	var rawEndpoint string
-	line := bufio.NewScanner(lease)
-	for line.Scan() {
-		parts := strings.Split(line.Text(), "=")
-		if parts[0] == "OPTION_245" && len(parts) == 2 {
-			rawEndpoint = parts[1]
-			break
-		}
-	}
 
 	if len(rawEndpoint) == 0 || len(rawEndpoint) != 8 {
 		return nil, fmt.Errorf("fabric endpoint not found in leases")
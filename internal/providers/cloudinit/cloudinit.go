@@ -0,0 +1,108 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cloudinit parses the parts of cloud-init meta-data and user-data
+// which are shared by the datasources that use them.
+package cloudinit
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// UserData holds the keys of a #cloud-config user-data document which are
+// applied by coreos-metadata.
+type UserData struct {
+	Hostname          string   `yaml:"hostname"`
+	FQDN              string   `yaml:"fqdn"`
+	SshAuthorizedKeys []string `yaml:"ssh_authorized_keys"`
+}
+
+// ParseUserData reads a user-data document. Documents other than
+// #cloud-config, e.g. scripts, are ignored.
+func ParseUserData(body []byte) (UserData, error) {
+	var user UserData
+	if !strings.HasPrefix(string(body), "#cloud-config") {
+		return user, nil
+	}
+	if err := yaml.Unmarshal(body, &user); err != nil {
+		return UserData{}, err
+	}
+	return user, nil
+}
+
+// Hostname returns the hostname chosen by cloud-init: the fqdn from
+// user-data, then the hostname from user-data, then the local-hostname from
+// meta-data.
+func Hostname(localHostname string, user UserData) string {
+	switch {
+	case user.FQDN != "":
+		return user.FQDN
+	case user.Hostname != "":
+		return user.Hostname
+	default:
+		return localHostname
+	}
+}
+
+// ParsePublicKeys accepts the forms used by cloud-init for public-keys: a
+// single string, a list of strings, or a map of key names to keys, which
+// are returned ordered by name. Maps decoded from either YAML or JSON are
+// accepted.
+func ParsePublicKeys(value interface{}) ([]string, error) {
+	var keys []string
+	switch v := value.(type) {
+	case nil:
+	case string:
+		for _, key := range strings.Split(v, "\n") {
+			if key = strings.TrimSpace(key); key != "" {
+				keys = append(keys, key)
+			}
+		}
+	case []interface{}:
+		for _, key := range v {
+			k, err := ParsePublicKeys(key)
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, k...)
+		}
+	case map[interface{}]interface{}:
+		byName := make(map[string]interface{}, len(v))
+		for name, key := range v {
+			byName[fmt.Sprint(name)] = key
+		}
+		return ParsePublicKeys(byName)
+	case map[string]interface{}:
+		// order the keys by name, as the map is unordered
+		var names []string
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			k, err := ParsePublicKeys(v[name])
+			if err != nil {
+				return nil, err
+			}
+			keys = append(keys, k...)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported public-keys value: %v", value)
+	}
+	return keys, nil
+}
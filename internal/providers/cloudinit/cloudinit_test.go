@@ -0,0 +1,111 @@
+package cloudinit
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePublicKeys(t *testing.T) {
+	tests := []struct {
+		desc  string
+		value interface{}
+		keys  []string
+		err   bool
+	}{
+		{
+			desc:  "string",
+			value: "ssh-rsa AAA\n\n  ssh-rsa BBB  \n",
+			keys:  []string{"ssh-rsa AAA", "ssh-rsa BBB"},
+		},
+		{
+			desc:  "list",
+			value: []interface{}{"ssh-rsa AAA", "ssh-rsa BBB"},
+			keys:  []string{"ssh-rsa AAA", "ssh-rsa BBB"},
+		},
+		{
+			desc: "yaml map",
+			value: map[interface{}]interface{}{
+				"c": "ssh-rsa CCC",
+				"a": "ssh-rsa AAA",
+				"b": []interface{}{"ssh-rsa BBB"},
+			},
+			keys: []string{"ssh-rsa AAA", "ssh-rsa BBB", "ssh-rsa CCC"},
+		},
+		{
+			desc: "json map",
+			value: map[string]interface{}{
+				"c": "ssh-rsa CCC",
+				"a": "ssh-rsa AAA",
+				"b": []interface{}{"ssh-rsa BBB"},
+			},
+			keys: []string{"ssh-rsa AAA", "ssh-rsa BBB", "ssh-rsa CCC"},
+		},
+		{
+			desc:  "unset",
+			value: nil,
+		},
+		{
+			desc:  "unsupported",
+			value: 42,
+			err:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		// maps are unordered, so repeat to catch unstable ordering
+		for i := 0; i < 10; i++ {
+			keys, err := ParsePublicKeys(tt.value)
+			if tt.err {
+				if err == nil {
+					t.Errorf("%s: expected an error", tt.desc)
+				}
+				break
+			}
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", tt.desc, err)
+				break
+			}
+			if !reflect.DeepEqual(keys, tt.keys) {
+				t.Errorf("%s:\nwant: %q\n got: %q", tt.desc, tt.keys, keys)
+				break
+			}
+		}
+	}
+}
+
+func TestHostname(t *testing.T) {
+	tests := []struct {
+		desc     string
+		local    string
+		user     UserData
+		hostname string
+	}{
+		{desc: "fqdn", local: "local", user: UserData{Hostname: "node1", FQDN: "node1.example.com"}, hostname: "node1.example.com"},
+		{desc: "hostname", local: "local", user: UserData{Hostname: "node1"}, hostname: "node1"},
+		{desc: "local-hostname", local: "local", hostname: "local"},
+	}
+
+	for _, tt := range tests {
+		if hostname := Hostname(tt.local, tt.user); hostname != tt.hostname {
+			t.Errorf("%s: want %q, got %q", tt.desc, tt.hostname, hostname)
+		}
+	}
+}
+
+func TestParseUserData(t *testing.T) {
+	user, err := ParseUserData([]byte("#cloud-config\nhostname: node1\nssh_authorized_keys:\n  - ssh-rsa AAA\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := UserData{Hostname: "node1", SshAuthorizedKeys: []string{"ssh-rsa AAA"}}
+	if !reflect.DeepEqual(user, want) {
+		t.Errorf("\nwant: %#v\n got: %#v", want, user)
+	}
+
+	if user, err := ParseUserData([]byte("#!/bin/sh\nhostname: node1\n")); err != nil || !reflect.DeepEqual(user, UserData{}) {
+		t.Errorf("script: got %#v, %v", user, err)
+	}
+	if _, err := ParseUserData([]byte("#cloud-config\nhostname: [\n")); err == nil {
+		t.Error("expected an error for invalid user-data")
+	}
+}
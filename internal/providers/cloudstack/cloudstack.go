@@ -0,0 +1,136 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cloudstack fetches metadata from the CloudStack virtual router,
+// which is also used by Exoscale.
+package cloudstack
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/coreos/coreos-metadata/internal/lease"
+	"github.com/coreos/coreos-metadata/internal/providers"
+	"github.com/coreos/coreos-metadata/internal/retry"
+)
+
+var (
+	// leaseOption looks up the virtual router in the DHCP lease.
+	leaseOption  = lease.GetOption
+	metadataPort = "80"
+)
+
+// Schema describes the attributes produced by FetchMetadata.
+var Schema = providers.Schema{
+	{Name: "CLOUDSTACK_HOSTNAME", Description: "The hostname of the instance", Type: providers.TypeString, Optional: true},
+	{Name: "CLOUDSTACK_INSTANCE_ID", Description: "The ID of the instance", Type: providers.TypeString, Optional: true},
+	{Name: "CLOUDSTACK_IPV4_LOCAL", Description: "The private IPv4 address", Type: providers.TypeIPv4, Optional: true},
+	{Name: "CLOUDSTACK_IPV4_PUBLIC", Description: "The public IPv4 address", Type: providers.TypeIPv4, Optional: true},
+	{Name: "CLOUDSTACK_SERVICE_OFFERING", Description: "The service offering of the instance", Type: providers.TypeString, Optional: true},
+	{Name: "CLOUDSTACK_AVAILABILITY_ZONE", Description: "The availability zone of the instance", Type: providers.TypeString, Optional: true},
+}
+
+func FetchMetadata() (providers.Metadata, error) {
+	router, err := getVirtualRouterAddress()
+	if err != nil {
+		return providers.Metadata{}, err
+	}
+	endpoint := fmt.Sprintf("http://%s/latest/meta-data/", net.JoinHostPort(router.String(), metadataPort))
+
+	m := providers.Metadata{}
+	m.Attributes = make(map[string]string)
+
+	for key, attrKey := range map[string]string{
+		"instance-id":       "CLOUDSTACK_INSTANCE_ID",
+		"local-hostname":    "CLOUDSTACK_HOSTNAME",
+		"local-ipv4":        "CLOUDSTACK_IPV4_LOCAL",
+		"public-ipv4":       "CLOUDSTACK_IPV4_PUBLIC",
+		"service-offering":  "CLOUDSTACK_SERVICE_OFFERING",
+		"availability-zone": "CLOUDSTACK_AVAILABILITY_ZONE",
+	} {
+		if err := fetchAndSet(endpoint, key, attrKey, m.Attributes); err != nil {
+			return providers.Metadata{}, err
+		}
+	}
+	m.Hostname = m.Attributes["CLOUDSTACK_HOSTNAME"]
+	m.Common = providers.Common{
+		PrivateIPv4: net.ParseIP(m.Attributes["CLOUDSTACK_IPV4_LOCAL"]),
+		PublicIPv4:  net.ParseIP(m.Attributes["CLOUDSTACK_IPV4_PUBLIC"]),
+		Zone:        m.Attributes["CLOUDSTACK_AVAILABILITY_ZONE"],
+		InstanceID:  m.Attributes["CLOUDSTACK_INSTANCE_ID"],
+	}
+
+	keys, err := fetchKeys(endpoint)
+	if err != nil {
+		return providers.Metadata{}, err
+	}
+	m.SshKeys = keys
+
+	return m, nil
+}
+
+func getVirtualRouterAddress() (net.IP, error) {
+	server, err := leaseOption("SERVER_ADDRESS")
+	if err != nil {
+		return nil, err
+	}
+
+	addr := net.ParseIP(server)
+	if addr == nil {
+		return nil, fmt.Errorf("virtual router address not found in leases")
+	}
+
+	return addr, nil
+}
+
+func fetchAndSet(endpoint, key, attrKey string, attributes map[string]string) error {
+	val, ok, err := fetchMetadata(endpoint, key)
+	if err != nil {
+		return err
+	}
+	if !ok || val == "" {
+		return nil
+	}
+	attributes[attrKey] = val
+	return nil
+}
+
+func fetchKeys(endpoint string) ([]string, error) {
+	keysBlob, ok, err := fetchMetadata(endpoint, "public-keys")
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, nil
+	}
+
+	var keys []string
+	for _, key := range strings.Split(keysBlob, "\n") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
+}
+
+func fetchMetadata(endpoint, key string) (string, bool, error) {
+	body, err := retry.Client{
+		InitialBackoff: time.Second,
+		MaxBackoff:     time.Second * 5,
+		MaxAttempts:    10,
+	}.Get(endpoint + key)
+	return strings.TrimSpace(string(body)), (body != nil), err
+}
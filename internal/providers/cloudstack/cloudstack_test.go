@@ -0,0 +1,121 @@
+package cloudstack
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/coreos/coreos-metadata/internal/providers"
+)
+
+var testMetadata = map[string]string{
+	"/latest/meta-data/instance-id":       "4dd7a2f8-8b5e-4d5c-9e0c-6f1e1f3a6b1c",
+	"/latest/meta-data/local-hostname":    "node1",
+	"/latest/meta-data/local-ipv4":        "10.1.1.5",
+	"/latest/meta-data/public-ipv4":       "185.19.28.10\n",
+	"/latest/meta-data/service-offering":  "Medium",
+	"/latest/meta-data/availability-zone": "ch-gva-2",
+	"/latest/meta-data/public-keys":       "ssh-rsa AAAA core@example\n\n  ssh-ed25519 AAAAC3 core@laptop  \n",
+}
+
+func TestFetchMetadata(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		value, ok := testMetadata[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(value))
+	}))
+	defer server.Close()
+
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, port, err := net.SplitHostPort(u.Host)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func(option func(string) (string, error), port string) {
+		leaseOption = option
+		metadataPort = port
+	}(leaseOption, metadataPort)
+	leaseOption = func(key string) (string, error) {
+		if key != "SERVER_ADDRESS" {
+			return "", nil
+		}
+		return host, nil
+	}
+	metadataPort = port
+
+	want := providers.Metadata{
+		Attributes: map[string]string{
+			"CLOUDSTACK_INSTANCE_ID":       "4dd7a2f8-8b5e-4d5c-9e0c-6f1e1f3a6b1c",
+			"CLOUDSTACK_HOSTNAME":          "node1",
+			"CLOUDSTACK_IPV4_LOCAL":        "10.1.1.5",
+			"CLOUDSTACK_IPV4_PUBLIC":       "185.19.28.10",
+			"CLOUDSTACK_SERVICE_OFFERING":  "Medium",
+			"CLOUDSTACK_AVAILABILITY_ZONE": "ch-gva-2",
+		},
+		Hostname: "node1",
+		SshKeys:  []string{"ssh-rsa AAAA core@example", "ssh-ed25519 AAAAC3 core@laptop"},
+		Common: providers.Common{
+			PrivateIPv4: net.ParseIP("10.1.1.5"),
+			PublicIPv4:  net.ParseIP("185.19.28.10"),
+			Zone:        "ch-gva-2",
+			InstanceID:  "4dd7a2f8-8b5e-4d5c-9e0c-6f1e1f3a6b1c",
+		},
+	}
+
+	got, err := FetchMetadata()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("bad metadata:\nwant: %#v\n got: %#v", want, got)
+	}
+	if err := Schema.Validate(got.Attributes); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGetVirtualRouterAddress(t *testing.T) {
+	tests := []struct {
+		desc   string
+		option string
+		err    error
+		router net.IP
+	}{
+		{desc: "server address", option: "10.1.1.1", router: net.ParseIP("10.1.1.1")},
+		{desc: "missing server address", option: ""},
+		{desc: "invalid server address", option: "router"},
+		{desc: "lease error", err: errors.New("no lease")},
+	}
+
+	defer func(option func(string) (string, error)) { leaseOption = option }(leaseOption)
+	for _, tt := range tests {
+		option, optionErr := tt.option, tt.err
+		leaseOption = func(string) (string, error) { return option, optionErr }
+
+		router, err := getVirtualRouterAddress()
+		if tt.router == nil {
+			if err == nil {
+				t.Errorf("%s: expected an error, got %v", tt.desc, router)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.desc, err)
+			continue
+		}
+		if !router.Equal(tt.router) {
+			t.Errorf("%s: want %v, got %v", tt.desc, tt.router, router)
+		}
+	}
+}
@@ -49,6 +49,7 @@ type DNS struct {
 }
 
 type Metadata struct {
+	DropletID  int        `json:"droplet_id"`
 	Hostname   string     `json:"hostname"`
 	Interfaces Interfaces `json:"interfaces"`
 	PublicKeys []string   `json:"public_keys"`
@@ -56,6 +57,17 @@ type Metadata struct {
 	DNS        DNS        `json:"dns"`
 }
 
+// Schema describes the attributes produced by FetchMetadata.
+var Schema = providers.Schema{
+	{Name: "DIGITALOCEAN_HOSTNAME", Description: "The hostname of the droplet", Type: providers.TypeString},
+	{Name: "DIGITALOCEAN_REGION", Description: "The region of the droplet", Type: providers.TypeString},
+	{Name: "DIGITALOCEAN_IPV4_PUBLIC", Description: "The IPv4 address of the nth public interface", Type: providers.TypeIPv4, Indexed: true},
+	{Name: "DIGITALOCEAN_IPV6_PUBLIC", Description: "The IPv6 address of the nth public interface", Type: providers.TypeIPv6, Indexed: true},
+	{Name: "DIGITALOCEAN_IPV4_ANCHOR", Description: "The anchor IPv4 address of the nth public interface, used by floating IPs", Type: providers.TypeIPv4, Indexed: true},
+	{Name: "DIGITALOCEAN_IPV4_PRIVATE", Description: "The IPv4 address of the nth private interface", Type: providers.TypeIPv4, Indexed: true},
+	{Name: "DIGITALOCEAN_IPV6_PRIVATE", Description: "The IPv6 address of the nth private interface", Type: providers.TypeIPv6, Indexed: true},
+}
+
 func FetchMetadata() (providers.Metadata, error) {
 	body, err := retry.Client{
 		InitialBackoff: time.Second,
@@ -82,9 +94,31 @@ func FetchMetadata() (providers.Metadata, error) {
 		Hostname:   m.Hostname,
 		Network:    network,
 		SshKeys:    m.PublicKeys,
+		Common:     parseCommon(m),
 	}, nil
 }
 
+func parseCommon(metadata Metadata) providers.Common {
+	common := providers.Common{Region: metadata.Region}
+	if metadata.DropletID != 0 {
+		common.InstanceID = fmt.Sprint(metadata.DropletID)
+	}
+	for _, iface := range metadata.Interfaces.Public {
+		if iface.IPv4 != nil {
+			common.PublicIPv4 = net.ParseIP(iface.IPv4.IPAddress)
+			break
+		}
+	}
+	for _, iface := range metadata.Interfaces.Private {
+		if iface.IPv4 != nil {
+			common.PrivateIPv4 = net.ParseIP(iface.IPv4.IPAddress)
+			break
+		}
+	}
+
+	return common
+}
+
 func parseAttributes(metadata Metadata) map[string]string {
 	attrs := map[string]string{
 		"DIGITALOCEAN_HOSTNAME": metadata.Hostname,
@@ -126,6 +160,7 @@ func parseNetwork(metadata Metadata) ([]providers.NetworkInterface, error) {
 		return nil, err
 	}
 
+	var macs []string
 	ifaceConfigs := map[string]providers.NetworkInterface{}
 	for _, iface := range append(metadata.Interfaces.Private, metadata.Interfaces.Public...) {
 		mac, err := net.ParseMAC(iface.MAC)
@@ -137,8 +172,13 @@ func parseNetwork(metadata Metadata) ([]providers.NetworkInterface, error) {
 			return nil, err
 		}
 
+		if _, ok := ifaceConfigs[iface.MAC]; !ok {
+			macs = append(macs, iface.MAC)
+		}
+
 		ifaceConfigs[iface.MAC] = providers.NetworkInterface{
 			HardwareAddress: mac,
+			Role:            iface.Type,
 			Nameservers:     servers,
 			IPAddresses:     append(ifaceConfigs[iface.MAC].IPAddresses, addrs...),
 			Routes:          append(ifaceConfigs[iface.MAC].Routes, routes...),
@@ -146,8 +186,8 @@ func parseNetwork(metadata Metadata) ([]providers.NetworkInterface, error) {
 	}
 
 	var ifaces []providers.NetworkInterface
-	for _, iface := range ifaceConfigs {
-		ifaces = append(ifaces, iface)
+	for _, mac := range macs {
+		ifaces = append(ifaces, ifaceConfigs[mac])
 	}
 	return ifaces, nil
 }
@@ -15,11 +15,9 @@
 package ec2
 
 import (
-	"bufio"
 	"encoding/json"
 	"fmt"
 	"net"
-	"strings"
 	"time"
 
 	"github.com/coreos/coreos-metadata/internal/providers"
@@ -43,6 +41,16 @@ type instanceIdDoc struct {
 	ImageId            string `json:"imageId"`
 }
 
+// Schema describes the attributes produced by FetchMetadata.
+var Schema = providers.Schema{
+	{Name: "EC2_INSTANCE_ID", Description: "The ID of the instance", Type: providers.TypeString},
+	{Name: "EC2_IPV4_LOCAL", Description: "The private IPv4 address", Type: providers.TypeIPv4, Optional: true},
+	{Name: "EC2_IPV4_PUBLIC", Description: "The public IPv4 address", Type: providers.TypeIPv4, Optional: true},
+	{Name: "EC2_HOSTNAME", Description: "The private hostname of the instance", Type: providers.TypeString, Optional: true},
+	{Name: "EC2_AVAILABILITY_ZONE", Description: "The availability zone of the instance", Type: providers.TypeString, Optional: true},
+	{Name: "EC2_REGION", Description: "The region of the instance", Type: providers.TypeString, Optional: true},
+}
+
 func FetchMetadata() (providers.Metadata, error) {
 	instanceId, _, err := fetchString("meta-data/instance-id")
 	if err != nil {
@@ -76,7 +84,7 @@ func FetchMetadata() (providers.Metadata, error) {
 		return providers.Metadata{}, err
 	}
 
-	sshKeys, err := fetchSshKeys()
+	sshKeys, err := providers.FetchEC2Keys(fetchString, "meta-data")
 	if err != nil {
 		return providers.Metadata{}, err
 	}
@@ -92,6 +100,13 @@ func FetchMetadata() (providers.Metadata, error) {
 		},
 		Hostname: hostname,
 		SshKeys:  sshKeys,
+		Common: providers.Common{
+			PrivateIPv4: local,
+			PublicIPv4:  public,
+			Region:      instanceIdDoc.Region,
+			Zone:        availabilityZone,
+			InstanceID:  instanceId,
+		},
 	}, nil
 }
 
@@ -120,43 +135,3 @@ func fetchIP(key string) (net.IP, error) {
 		return nil, fmt.Errorf("couldn't parse %q as IP address", str)
 	}
 }
-
-func fetchSshKeys() ([]string, error) {
-	keydata, present, err := fetchString("meta-data/public-keys")
-	if err != nil {
-		return nil, fmt.Errorf("error reading keys: %v", err)
-	}
-
-	if !present {
-		return nil, nil
-	}
-
-	scanner := bufio.NewScanner(strings.NewReader(keydata))
-	keynames := []string{}
-	for scanner.Scan() {
-		keynames = append(keynames, scanner.Text())
-	}
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("error parsing keys: %v", err)
-	}
-
-	keyIDs := make(map[string]string)
-	for _, keyname := range keynames {
-		tokens := strings.SplitN(keyname, "=", 2)
-		if len(tokens) != 2 {
-			return nil, fmt.Errorf("malformed public key: %q", keyname)
-		}
-		keyIDs[tokens[1]] = tokens[0]
-	}
-
-	keys := []string{}
-	for _, id := range keyIDs {
-		sshkey, _, err := fetchString(fmt.Sprintf("meta-data/public-keys/%s/openssh-key", id))
-		if err != nil {
-			return nil, err
-		}
-		keys = append(keys, sshkey)
-	}
-
-	return keys, nil
-}
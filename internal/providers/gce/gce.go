@@ -25,6 +25,13 @@ import (
 	"github.com/coreos/coreos-metadata/internal/retry"
 )
 
+// Schema describes the attributes produced by FetchMetadata.
+var Schema = providers.Schema{
+	{Name: "GCE_IP_LOCAL_0", Description: "The private IPv4 address of the first interface", Type: providers.TypeIPv4, Optional: true},
+	{Name: "GCE_IP_EXTERNAL_0", Description: "The external IPv4 address of the first interface", Type: providers.TypeIPv4, Optional: true},
+	{Name: "GCE_HOSTNAME", Description: "The hostname of the instance", Type: providers.TypeString, Optional: true},
+}
+
 func FetchMetadata() (providers.Metadata, error) {
 	public, err := fetchIP("instance/network-interfaces/0/access-configs/0/external-ip")
 	if err != nil {
@@ -38,6 +45,18 @@ func FetchMetadata() (providers.Metadata, error) {
 	if err != nil {
 		return providers.Metadata{}, err
 	}
+	// the instance ID and zone only feed the provider-neutral attributes,
+	// so don't fail if they can't be fetched
+	instanceId, _, err := fetchString("instance/id")
+	if err != nil {
+		fmt.Printf("Failed to fetch the instance ID: %v\n", err)
+	}
+	// the zone is reported as projects/<project>/zones/<zone>
+	zone, _, err := fetchString("instance/zone")
+	if err != nil {
+		fmt.Printf("Failed to fetch the zone: %v\n", err)
+	}
+	zone = zone[strings.LastIndex(zone, "/")+1:]
 	sshKeys, err := fetchAllSshKeys()
 	if err != nil {
 		return providers.Metadata{}, err
@@ -51,6 +70,13 @@ func FetchMetadata() (providers.Metadata, error) {
 		},
 		Hostname: hostname,
 		SshKeys:  sshKeys,
+		Common: providers.Common{
+			PrivateIPv4: local,
+			PublicIPv4:  public,
+			Region:      providers.RegionOfZone(zone),
+			Zone:        zone,
+			InstanceID:  instanceId,
+		},
 	}, nil
 }
 
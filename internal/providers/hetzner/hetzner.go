@@ -0,0 +1,184 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hetzner
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/coreos/coreos-metadata/internal/providers"
+	"github.com/coreos/coreos-metadata/internal/retry"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	metadataEndpoint = "http://169.254.169.254/hetzner/v1/metadata"
+)
+
+type Metadata struct {
+	Hostname         string   `yaml:"hostname"`
+	InstanceID       string   `yaml:"instance-id"`
+	PublicIPv4       string   `yaml:"public-ipv4"`
+	PublicKeys       []string `yaml:"public-keys"`
+	Region           string   `yaml:"region"`
+	AvailabilityZone string   `yaml:"availability-zone"`
+}
+
+type PrivateNetwork struct {
+	IP         string   `yaml:"ip"`
+	AliasIPs   []string `yaml:"alias_ips"`
+	MACAddress string   `yaml:"mac_address"`
+	Network    string   `yaml:"network"`
+	Subnet     string   `yaml:"subnet"`
+	Gateway    string   `yaml:"gateway"`
+}
+
+// Schema describes the attributes produced by FetchMetadata.
+var Schema = providers.Schema{
+	{Name: "HETZNER_HOSTNAME", Description: "The hostname of the server", Type: providers.TypeString},
+	{Name: "HETZNER_INSTANCE_ID", Description: "The ID of the server", Type: providers.TypeInteger},
+	{Name: "HETZNER_IPV4_PUBLIC", Description: "The public IPv4 address", Type: providers.TypeIPv4, Optional: true},
+	{Name: "HETZNER_IPV4_PRIVATE", Description: "The IPv4 address in the nth private network", Type: providers.TypeIPv4, Indexed: true},
+	{Name: "HETZNER_REGION", Description: "The network zone of the server", Type: providers.TypeString, Optional: true},
+	{Name: "HETZNER_AVAILABILITY_ZONE", Description: "The location of the server", Type: providers.TypeString, Optional: true},
+}
+
+func FetchMetadata() (providers.Metadata, error) {
+	client := retry.Client{
+		InitialBackoff: time.Second,
+		MaxBackoff:     time.Second * 5,
+		MaxAttempts:    10,
+	}
+
+	body, err := client.Get(metadataEndpoint)
+	if err != nil {
+		return providers.Metadata{}, fmt.Errorf("failed to fetch metadata: %v", err)
+	}
+
+	var m Metadata
+	if err := yaml.Unmarshal(body, &m); err != nil {
+		return providers.Metadata{}, fmt.Errorf("failed to unmarshal metadata: %v", err)
+	}
+
+	body, err = client.Get(metadataEndpoint + "/private-networks")
+	if err != nil {
+		return providers.Metadata{}, fmt.Errorf("failed to fetch private networks: %v", err)
+	}
+
+	var privateNetworks []PrivateNetwork
+	if err := yaml.Unmarshal(body, &privateNetworks); err != nil {
+		return providers.Metadata{}, fmt.Errorf("failed to unmarshal private networks: %v", err)
+	}
+
+	network, err := parseNetwork(privateNetworks)
+	if err != nil {
+		return providers.Metadata{}, fmt.Errorf("failed to parse network config from metadata: %v", err)
+	}
+
+	return providers.Metadata{
+		Attributes: parseAttributes(m, privateNetworks),
+		Hostname:   m.Hostname,
+		Network:    network,
+		SshKeys:    m.PublicKeys,
+		Common:     parseCommon(m, privateNetworks),
+	}, nil
+}
+
+func parseCommon(metadata Metadata, privateNetworks []PrivateNetwork) providers.Common {
+	common := providers.Common{
+		PublicIPv4: net.ParseIP(metadata.PublicIPv4),
+		Region:     metadata.Region,
+		Zone:       metadata.AvailabilityZone,
+		InstanceID: metadata.InstanceID,
+	}
+	if len(privateNetworks) > 0 {
+		common.PrivateIPv4 = net.ParseIP(privateNetworks[0].IP)
+	}
+
+	return common
+}
+
+func parseAttributes(metadata Metadata, privateNetworks []PrivateNetwork) map[string]string {
+	attrs := map[string]string{
+		"HETZNER_HOSTNAME":          metadata.Hostname,
+		"HETZNER_INSTANCE_ID":       metadata.InstanceID,
+		"HETZNER_IPV4_PUBLIC":       providers.String(net.ParseIP(metadata.PublicIPv4)),
+		"HETZNER_REGION":            metadata.Region,
+		"HETZNER_AVAILABILITY_ZONE": metadata.AvailabilityZone,
+	}
+
+	for i, network := range privateNetworks {
+		attrs[fmt.Sprintf("HETZNER_IPV4_PRIVATE_%d", i)] =
+			providers.String(net.ParseIP(network.IP))
+	}
+
+	return attrs
+}
+
+// parseNetwork describes the private network interfaces. The public
+// interface is configured using DHCP and is not included.
+func parseNetwork(privateNetworks []PrivateNetwork) ([]providers.NetworkInterface, error) {
+	var ifaces []providers.NetworkInterface
+	for _, network := range privateNetworks {
+		mac, err := net.ParseMAC(network.MACAddress)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse %q as MAC address", network.MACAddress)
+		}
+
+		_, subnet, err := net.ParseCIDR(network.Subnet)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse %q as subnet", network.Subnet)
+		}
+
+		iface := providers.NetworkInterface{
+			HardwareAddress: mac,
+			Role:            "private",
+		}
+
+		for _, addr := range append([]string{network.IP}, network.AliasIPs...) {
+			ip := net.ParseIP(addr)
+			if ip == nil {
+				return nil, fmt.Errorf("could not parse %q as IP address", addr)
+			}
+			iface.IPAddresses = append(iface.IPAddresses, net.IPNet{
+				IP:   ip,
+				Mask: subnet.Mask,
+			})
+		}
+
+		if network.Gateway != "" && network.Network != "" {
+			gateway := net.ParseIP(network.Gateway)
+			if gateway == nil {
+				return nil, fmt.Errorf("could not parse %q as gateway address", network.Gateway)
+			}
+
+			_, destination, err := net.ParseCIDR(network.Network)
+			if err != nil {
+				return nil, fmt.Errorf("could not parse %q as network", network.Network)
+			}
+
+			iface.Routes = append(iface.Routes, providers.NetworkRoute{
+				Destination: *destination,
+				Gateway:     gateway,
+			})
+		}
+
+		ifaces = append(ifaces, iface)
+	}
+
+	return ifaces, nil
+}
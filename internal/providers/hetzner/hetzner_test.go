@@ -0,0 +1,64 @@
+package hetzner
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/coreos/coreos-metadata/internal/providers"
+
+	"gopkg.in/yaml.v2"
+)
+
+func TestParseNetwork(t *testing.T) {
+	const privateNetworks = `
+- ip: 10.0.0.2
+  alias_ips: [10.0.0.3]
+  interface_num: 1
+  mac_address: 86:00:00:2a:7d:e0
+  network_id: 1234
+  network_name: nw-test1
+  network: 10.0.0.0/8
+  subnet: 10.0.0.0/24
+  gateway: 10.0.0.1
+`
+
+	var networks []PrivateNetwork
+	if err := yaml.Unmarshal([]byte(privateNetworks), &networks); err != nil {
+		t.Fatalf("failed to unmarshal private networks: %v", err)
+	}
+
+	mac, _ := net.ParseMAC("86:00:00:2a:7d:e0")
+	want := []providers.NetworkInterface{
+		{
+			HardwareAddress: mac,
+			Role:            "private",
+			IPAddresses: []net.IPNet{
+				{IP: net.ParseIP("10.0.0.2"), Mask: net.CIDRMask(24, 32)},
+				{IP: net.ParseIP("10.0.0.3"), Mask: net.CIDRMask(24, 32)},
+			},
+			Routes: []providers.NetworkRoute{
+				{
+					Destination: net.IPNet{IP: net.IPv4(10, 0, 0, 0).To4(), Mask: net.CIDRMask(8, 32)},
+					Gateway:     net.ParseIP("10.0.0.1"),
+				},
+			},
+		},
+	}
+
+	got, err := parseNetwork(networks)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("\nwant: %#v\n got: %#v", want, got)
+	}
+
+	attrs := parseAttributes(Metadata{Hostname: "my-server", InstanceID: "42"}, networks)
+	if attrs["HETZNER_IPV4_PRIVATE_0"] != "10.0.0.2" || attrs["HETZNER_INSTANCE_ID"] != "42" {
+		t.Errorf("unexpected attributes: %v", attrs)
+	}
+	if err := Schema.Validate(attrs); err != nil {
+		t.Error(err)
+	}
+}
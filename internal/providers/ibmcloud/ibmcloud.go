@@ -0,0 +1,233 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ibmcloud
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/coreos/coreos-metadata/internal/providers"
+	"github.com/coreos/coreos-metadata/internal/retry"
+)
+
+const (
+	metadataEndpoint = "http://169.254.169.254/"
+	apiVersion       = "2022-03-01"
+
+	// TokenTTL is the lifetime requested for the instance identity token.
+	TokenTTL = time.Hour
+)
+
+type Reference struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type Instance struct {
+	ID      string    `json:"id"`
+	Name    string    `json:"name"`
+	Zone    Reference `json:"zone"`
+	Profile Reference `json:"profile"`
+	VPC     Reference `json:"vpc"`
+}
+
+type Keys struct {
+	Keys []struct {
+		Name      string `json:"name"`
+		PublicKey string `json:"public_key"`
+	} `json:"keys"`
+}
+
+type NetworkInterface struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	MACAddress string `json:"mac_address"`
+	PrimaryIP  struct {
+		Address string `json:"address"`
+	} `json:"primary_ip"`
+	Subnet Reference `json:"subnet"`
+}
+
+type NetworkInterfaces struct {
+	NetworkInterfaces []NetworkInterface `json:"network_interfaces"`
+}
+
+// Schema describes the attributes produced by FetchMetadata.
+var Schema = providers.Schema{
+	{Name: "IBMCLOUD_INSTANCE_ID", Description: "The ID of the instance", Type: providers.TypeString},
+	{Name: "IBMCLOUD_HOSTNAME", Description: "The name of the instance", Type: providers.TypeString},
+	{Name: "IBMCLOUD_ZONE", Description: "The zone of the instance", Type: providers.TypeString, Optional: true},
+	{Name: "IBMCLOUD_PROFILE", Description: "The profile of the instance", Type: providers.TypeString, Optional: true},
+	{Name: "IBMCLOUD_VPC_ID", Description: "The ID of the VPC of the instance", Type: providers.TypeString, Optional: true},
+	{Name: "IBMCLOUD_IPV4_PRIVATE", Description: "The primary IPv4 address of the nth interface", Type: providers.TypeIPv4, Indexed: true},
+}
+
+func FetchMetadata() (providers.Metadata, error) {
+	token, err := fetchToken()
+	if err != nil {
+		return providers.Metadata{}, fmt.Errorf("failed to fetch instance identity token: %v", err)
+	}
+	client := getClient(token)
+
+	var instance Instance
+	if err := fetchJSON(client, "metadata/v1/instance", &instance); err != nil {
+		return providers.Metadata{}, fmt.Errorf("failed to fetch instance metadata: %v", err)
+	}
+
+	var keys Keys
+	if err := fetchJSON(client, "metadata/v1/keys", &keys); err != nil {
+		return providers.Metadata{}, fmt.Errorf("failed to fetch keys: %v", err)
+	}
+
+	var ifaces NetworkInterfaces
+	if err := fetchJSON(client, "metadata/v1/instance/network_interfaces", &ifaces); err != nil {
+		return providers.Metadata{}, fmt.Errorf("failed to fetch network interfaces: %v", err)
+	}
+
+	network, err := parseNetwork(ifaces.NetworkInterfaces)
+	if err != nil {
+		return providers.Metadata{}, fmt.Errorf("failed to parse network config from metadata: %v", err)
+	}
+
+	var sshKeys []string
+	for _, key := range keys.Keys {
+		sshKeys = append(sshKeys, key.PublicKey)
+	}
+
+	return providers.Metadata{
+		Attributes: parseAttributes(instance, ifaces.NetworkInterfaces),
+		Hostname:   instance.Name,
+		Network:    network,
+		SshKeys:    sshKeys,
+		Common:     parseCommon(instance, ifaces.NetworkInterfaces),
+	}, nil
+}
+
+func fetchToken() (string, error) {
+	request, err := json.Marshal(struct {
+		ExpiresIn int `json:"expires_in"`
+	}{int(TokenTTL.Seconds())})
+	if err != nil {
+		return "", err
+	}
+
+	body, err := retry.Client{
+		InitialBackoff: time.Second,
+		MaxBackoff:     time.Second * 5,
+		MaxAttempts:    10,
+		Header: map[string][]string{
+			"Metadata-Flavor": {"ibm"},
+			"Content-Type":    {"application/json"},
+			"Accept":          {"application/json"},
+		},
+	}.Put(metadataEndpoint+"instance_identity/v1/token?version="+apiVersion, request)
+	if err != nil {
+		return "", err
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil {
+		return "", err
+	}
+
+	return token.AccessToken, nil
+}
+
+func getClient(token string) retry.Client {
+	return retry.Client{
+		InitialBackoff: time.Second,
+		MaxBackoff:     time.Second * 5,
+		MaxAttempts:    10,
+		Header: map[string][]string{
+			"Authorization": {"Bearer " + token},
+			"Accept":        {"application/json"},
+		},
+	}
+}
+
+func fetchJSON(client retry.Client, key string, v interface{}) error {
+	body, err := client.Get(metadataEndpoint + key + "?version=" + apiVersion)
+	if err != nil {
+		return err
+	}
+	if body == nil {
+		return fmt.Errorf("%q not found", key)
+	}
+
+	return json.Unmarshal(body, v)
+}
+
+func parseAttributes(instance Instance, ifaces []NetworkInterface) map[string]string {
+	attrs := map[string]string{
+		"IBMCLOUD_INSTANCE_ID": instance.ID,
+		"IBMCLOUD_HOSTNAME":    instance.Name,
+		"IBMCLOUD_ZONE":        instance.Zone.Name,
+		"IBMCLOUD_PROFILE":     instance.Profile.Name,
+		"IBMCLOUD_VPC_ID":      instance.VPC.ID,
+	}
+
+	for i, iface := range ifaces {
+		attrs[fmt.Sprintf("IBMCLOUD_IPV4_PRIVATE_%d", i)] =
+			providers.String(net.ParseIP(iface.PrimaryIP.Address))
+	}
+
+	return attrs
+}
+
+func parseCommon(instance Instance, ifaces []NetworkInterface) providers.Common {
+	// zones are named after their region, e.g. us-south-1
+	zone := instance.Zone.Name
+
+	common := providers.Common{
+		Region:     providers.RegionOfZone(zone),
+		Zone:       zone,
+		InstanceID: instance.ID,
+	}
+	if len(ifaces) > 0 {
+		common.PrivateIPv4 = net.ParseIP(ifaces[0].PrimaryIP.Address)
+	}
+
+	return common
+}
+
+// parseNetwork describes every interface, matched by its hardware address.
+// The metadata service only references the subnets of the interfaces, without
+// their prefixes or gateways, so the interfaces are configured with DHCP,
+// which VPC uses to assign each interface its primary IP and the primary
+// interface its default route.
+func parseNetwork(ifaces []NetworkInterface) ([]providers.NetworkInterface, error) {
+	var configs []providers.NetworkInterface
+	for _, iface := range ifaces {
+		if iface.MACAddress == "" {
+			return nil, fmt.Errorf("network interface %q has no MAC address", iface.ID)
+		}
+
+		mac, err := net.ParseMAC(iface.MACAddress)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse %q as MAC address", iface.MACAddress)
+		}
+
+		configs = append(configs, providers.NetworkInterface{
+			HardwareAddress: mac,
+			DHCP:            providers.DHCPv4,
+		})
+	}
+
+	return configs, nil
+}
@@ -0,0 +1,51 @@
+package ibmcloud
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/coreos/coreos-metadata/internal/providers"
+)
+
+func TestParseNetwork(t *testing.T) {
+	body, err := ioutil.ReadFile("testdata/network_interfaces.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ifaces NetworkInterfaces
+	if err := json.Unmarshal(body, &ifaces); err != nil {
+		t.Fatal(err)
+	}
+
+	mac0, _ := net.ParseMAC("02:00:04:1e:6b:2d")
+	mac1, _ := net.ParseMAC("02:00:04:1e:6b:2e")
+	want := []providers.NetworkInterface{
+		{HardwareAddress: mac0, DHCP: providers.DHCPv4},
+		{HardwareAddress: mac1, DHCP: providers.DHCPv4},
+	}
+
+	got, err := parseNetwork(ifaces.NetworkInterfaces)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("\nwant: %#v\n got: %#v", want, got)
+	}
+
+	instance := Instance{ID: "0717_1e09", Name: "node1", Zone: Reference{Name: "us-south-1"}}
+	attrs := parseAttributes(instance, ifaces.NetworkInterfaces)
+	if attrs["IBMCLOUD_IPV4_PRIVATE_0"] != "10.240.0.4" || attrs["IBMCLOUD_IPV4_PRIVATE_1"] != "10.240.64.4" {
+		t.Errorf("unexpected attributes: %v", attrs)
+	}
+	if err := Schema.Validate(attrs); err != nil {
+		t.Error(err)
+	}
+
+	ifaces.NetworkInterfaces[1].MACAddress = ""
+	if _, err := parseNetwork(ifaces.NetworkInterfaces); err == nil {
+		t.Error("expected an error for an interface without a MAC address")
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package providers
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// FetchEC2Keys reads SSH keys from an EC2-style metadata service. The
+// "public-keys" listing contains one "<index>=<name>" (or "<index>/") entry
+// per key, and each key is then read from "public-keys/<index>/openssh-key".
+// fetch returns the body of the given key and whether it was present.
+func FetchEC2Keys(fetch func(key string) (string, bool, error), root string) ([]string, error) {
+	listing, present, err := fetch(path.Join(root, "public-keys"))
+	if err != nil {
+		return nil, fmt.Errorf("error reading keys: %v", err)
+	}
+
+	if !present {
+		return nil, nil
+	}
+
+	keys := []string{}
+	for _, entry := range strings.Split(listing, "\n") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		index := strings.TrimSuffix(strings.SplitN(entry, "=", 2)[0], "/")
+		if index == "" {
+			return nil, fmt.Errorf("malformed public key: %q", entry)
+		}
+
+		key, present, err := fetch(path.Join(root, "public-keys", index, "openssh-key"))
+		if err != nil {
+			return nil, err
+		}
+		if !present || key == "" {
+			return nil, fmt.Errorf("problem fetching key %q", entry)
+		}
+		keys = append(keys, strings.TrimSpace(key))
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,70 @@
+package providers
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestFetchEC2Keys(t *testing.T) {
+	tests := []struct {
+		desc string
+		root string
+		data map[string]string
+		keys []string
+		err  bool
+	}{
+		{
+			desc: "no keys",
+			root: "meta-data",
+			data: map[string]string{},
+			keys: nil,
+		},
+		{
+			desc: "indexed keys",
+			root: "meta-data",
+			data: map[string]string{
+				"meta-data/public-keys":               "0=alice\n1=bob",
+				"meta-data/public-keys/0/openssh-key": "ssh-rsa AAAA alice\n",
+				"meta-data/public-keys/1/openssh-key": "ssh-ed25519 BBBB bob",
+			},
+			keys: []string{"ssh-rsa AAAA alice", "ssh-ed25519 BBBB bob"},
+		},
+		{
+			desc: "directory listing",
+			root: "",
+			data: map[string]string{
+				"public-keys":               "0/\n",
+				"public-keys/0/openssh-key": "ssh-rsa AAAA alice",
+			},
+			keys: []string{"ssh-rsa AAAA alice"},
+		},
+		{
+			desc: "missing key",
+			root: "",
+			data: map[string]string{
+				"public-keys": "0=alice",
+			},
+			err: true,
+		},
+	}
+
+	for _, tt := range tests {
+		fetch := func(key string) (string, bool, error) {
+			if key == "" {
+				return "", false, errors.New("empty key")
+			}
+			value, ok := tt.data[key]
+			return value, ok, nil
+		}
+
+		keys, err := FetchEC2Keys(fetch, tt.root)
+		if (err != nil) != tt.err {
+			t.Errorf("%s: unexpected error state: %v", tt.desc, err)
+			continue
+		}
+		if !tt.err && !reflect.DeepEqual(keys, tt.keys) {
+			t.Errorf("%s:\nwant: %q\n got: %q", tt.desc, tt.keys, keys)
+		}
+	}
+}
@@ -0,0 +1,283 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package linode
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/coreos/coreos-metadata/internal/providers"
+	"github.com/coreos/coreos-metadata/internal/retry"
+)
+
+const (
+	metadataEndpoint = "http://169.254.169.254/v1/"
+
+	// TokenTTL is the lifetime requested for the Metadata API token.
+	TokenTTL = time.Hour
+)
+
+type Instance struct {
+	ID       int    `json:"id"`
+	HostUUID string `json:"host_uuid"`
+	Label    string `json:"label"`
+	Region   string `json:"region"`
+	Type     string `json:"type"`
+}
+
+type Interface struct {
+	Label       string `json:"label"`
+	Purpose     string `json:"purpose"`
+	IPAMAddress string `json:"ipam_address"`
+}
+
+type Network struct {
+	Interfaces []Interface `json:"interfaces"`
+	IPv4       struct {
+		Public  []string `json:"public"`
+		Private []string `json:"private"`
+	} `json:"ipv4"`
+	IPv6 struct {
+		SLAAC  string   `json:"slaac"`
+		Ranges []string `json:"ranges"`
+	} `json:"ipv6"`
+}
+
+type SshKeys struct {
+	Users map[string][]string `json:"users"`
+}
+
+// Schema describes the attributes produced by FetchMetadata.
+var Schema = providers.Schema{
+	{Name: "LINODE_HOSTNAME", Description: "The label of the Linode", Type: providers.TypeString},
+	{Name: "LINODE_INSTANCE_ID", Description: "The ID of the Linode", Type: providers.TypeInteger},
+	{Name: "LINODE_HOST_UUID", Description: "The UUID of the host of the Linode", Type: providers.TypeString, Optional: true},
+	{Name: "LINODE_REGION", Description: "The region of the Linode", Type: providers.TypeString, Optional: true},
+	{Name: "LINODE_TYPE", Description: "The plan of the Linode", Type: providers.TypeString, Optional: true},
+	{Name: "LINODE_IPV4_PUBLIC", Description: "The nth public IPv4 address", Type: providers.TypeIPv4, Indexed: true},
+	{Name: "LINODE_IPV4_PRIVATE", Description: "The nth private IPv4 address", Type: providers.TypeIPv4, Indexed: true},
+	{Name: "LINODE_IPV6_SLAAC", Description: "The IPv6 address configured through SLAAC", Type: providers.TypeIPv6, Optional: true},
+	{Name: "LINODE_IPV6_RANGE", Description: "The nth routed IPv6 range", Type: providers.TypeCIDR, Indexed: true},
+}
+
+func FetchMetadata() (providers.Metadata, error) {
+	token, err := fetchToken()
+	if err != nil {
+		return providers.Metadata{}, fmt.Errorf("failed to fetch metadata token: %v", err)
+	}
+	client := getClient(token)
+
+	var instance Instance
+	if err := fetchJSON(client, "instance", &instance); err != nil {
+		return providers.Metadata{}, fmt.Errorf("failed to fetch instance metadata: %v", err)
+	}
+
+	var network Network
+	if err := fetchJSON(client, "network", &network); err != nil {
+		return providers.Metadata{}, fmt.Errorf("failed to fetch network metadata: %v", err)
+	}
+
+	var keys SshKeys
+	if err := fetchJSON(client, "ssh-keys", &keys); err != nil {
+		return providers.Metadata{}, fmt.Errorf("failed to fetch ssh keys: %v", err)
+	}
+
+	ifaces, err := parseNetwork(network)
+	if err != nil {
+		return providers.Metadata{}, fmt.Errorf("failed to parse network config from metadata: %v", err)
+	}
+
+	return providers.Metadata{
+		Attributes: parseAttributes(instance, network),
+		Hostname:   instance.Label,
+		Network:    ifaces,
+		SshKeys:    parseKeys(keys),
+		Common:     parseCommon(instance, network),
+	}, nil
+}
+
+func fetchToken() (string, error) {
+	body, err := retry.Client{
+		InitialBackoff: time.Second,
+		MaxBackoff:     time.Second * 5,
+		MaxAttempts:    10,
+		Header: map[string][]string{
+			"Metadata-Token-Expiry-Seconds": {fmt.Sprint(int(TokenTTL.Seconds()))},
+		},
+	}.Put(metadataEndpoint+"token", nil)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(body)), nil
+}
+
+func getClient(token string) retry.Client {
+	return retry.Client{
+		InitialBackoff: time.Second,
+		MaxBackoff:     time.Second * 5,
+		MaxAttempts:    10,
+		Header: map[string][]string{
+			"Metadata-Token": {token},
+			"Accept":         {"application/json"},
+		},
+	}
+}
+
+func fetchJSON(client retry.Client, key string, v interface{}) error {
+	body, err := client.Get(metadataEndpoint + key)
+	if err != nil {
+		return err
+	}
+	if body == nil {
+		return fmt.Errorf("%q not found", key)
+	}
+
+	return json.Unmarshal(body, v)
+}
+
+func parseAttributes(instance Instance, network Network) map[string]string {
+	attrs := map[string]string{
+		"LINODE_HOSTNAME":    instance.Label,
+		"LINODE_INSTANCE_ID": fmt.Sprint(instance.ID),
+		"LINODE_HOST_UUID":   instance.HostUUID,
+		"LINODE_REGION":      instance.Region,
+		"LINODE_TYPE":        instance.Type,
+		"LINODE_IPV6_SLAAC":  stripPrefix(network.IPv6.SLAAC),
+	}
+
+	for i, addr := range network.IPv4.Public {
+		attrs[fmt.Sprintf("LINODE_IPV4_PUBLIC_%d", i)] = stripPrefix(addr)
+	}
+	for i, addr := range network.IPv4.Private {
+		attrs[fmt.Sprintf("LINODE_IPV4_PRIVATE_%d", i)] = stripPrefix(addr)
+	}
+	for i, r := range network.IPv6.Ranges {
+		attrs[fmt.Sprintf("LINODE_IPV6_RANGE_%d", i)] = r
+	}
+
+	return attrs
+}
+
+func parseCommon(instance Instance, network Network) providers.Common {
+	common := providers.Common{
+		Region:     instance.Region,
+		InstanceID: fmt.Sprint(instance.ID),
+	}
+	if len(network.IPv4.Public) > 0 {
+		common.PublicIPv4 = net.ParseIP(stripPrefix(network.IPv4.Public[0]))
+	}
+	if len(network.IPv4.Private) > 0 {
+		common.PrivateIPv4 = net.ParseIP(stripPrefix(network.IPv4.Private[0]))
+	}
+
+	return common
+}
+
+// parseKeys flattens the keys of every authorized user, ordered by user name.
+func parseKeys(keys SshKeys) []string {
+	var users []string
+	for user := range keys.Users {
+		users = append(users, user)
+	}
+	sort.Strings(users)
+
+	var all []string
+	for _, user := range users {
+		all = append(all, keys.Users[user]...)
+	}
+	return all
+}
+
+func stripPrefix(cidr string) string {
+	return strings.SplitN(cidr, "/", 2)[0]
+}
+
+// parseNetwork describes the public interface and any VLAN interfaces. The
+// metadata service does not report hardware addresses, so interfaces are
+// matched by name in the order in which they are attached (eth0, eth1, ...).
+// Linodes without configuration profile interfaces only have a public
+// interface.
+func parseNetwork(network Network) ([]providers.NetworkInterface, error) {
+	interfaces := network.Interfaces
+	if len(interfaces) == 0 {
+		interfaces = []Interface{{Purpose: "public"}}
+	}
+
+	var ifaces []providers.NetworkInterface
+	for i, iface := range interfaces {
+		config := providers.NetworkInterface{
+			Name: fmt.Sprintf("eth%d", i),
+		}
+
+		switch iface.Purpose {
+		case "public":
+			if err := addPublicAddresses(&config, network); err != nil {
+				return nil, err
+			}
+		case "vlan":
+			if iface.IPAMAddress == "" {
+				continue
+			}
+
+			ip, subnet, err := net.ParseCIDR(iface.IPAMAddress)
+			if err != nil {
+				return nil, fmt.Errorf("could not parse %q as IP address", iface.IPAMAddress)
+			}
+			config.IPAddresses = append(config.IPAddresses, net.IPNet{
+				IP:   ip,
+				Mask: subnet.Mask,
+			})
+		default:
+			continue
+		}
+
+		ifaces = append(ifaces, config)
+	}
+
+	return ifaces, nil
+}
+
+// addPublicAddresses configures the public interface. The metadata service
+// does not report gateways, so the primary IPv4 address and its gateway are
+// obtained through DHCP and the SLAAC address and default IPv6 route through
+// router advertisements. The other public and private IPv4 addresses are
+// added statically, without routes.
+func addPublicAddresses(config *providers.NetworkInterface, network Network) error {
+	config.DHCP = providers.DHCPv4 | providers.SLAAC
+
+	// the primary public address is leased through DHCP
+	public := network.IPv4.Public
+	if len(public) > 0 {
+		public = public[1:]
+	}
+
+	for _, addr := range append(append([]string{}, public...), network.IPv4.Private...) {
+		ip, subnet, err := net.ParseCIDR(addr)
+		if err != nil {
+			return fmt.Errorf("could not parse %q as IPv4 address", addr)
+		}
+		config.IPAddresses = append(config.IPAddresses, net.IPNet{
+			IP:   ip,
+			Mask: subnet.Mask,
+		})
+	}
+
+	return nil
+}
@@ -0,0 +1,102 @@
+package linode
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/coreos/coreos-metadata/internal/providers"
+)
+
+func readNetwork(t *testing.T, path string) Network {
+	body, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var network Network
+	if err := json.Unmarshal(body, &network); err != nil {
+		t.Fatal(err)
+	}
+	return network
+}
+
+func TestParseNetwork(t *testing.T) {
+	tests := []struct {
+		desc    string
+		fixture string
+		ifaces  []providers.NetworkInterface
+	}{
+		{
+			desc:    "public and vlan interfaces",
+			fixture: "testdata/network.json",
+			ifaces: []providers.NetworkInterface{
+				{
+					Name: "eth0",
+					DHCP: providers.DHCPv4 | providers.SLAAC,
+					IPAddresses: []net.IPNet{
+						{IP: net.ParseIP("203.0.113.11"), Mask: net.CIDRMask(24, 32)},
+						{IP: net.ParseIP("192.168.128.10"), Mask: net.CIDRMask(17, 32)},
+					},
+				},
+				{
+					Name: "eth1",
+					IPAddresses: []net.IPNet{
+						{IP: net.ParseIP("10.0.0.2"), Mask: net.CIDRMask(24, 32)},
+					},
+				},
+			},
+		},
+		{
+			desc:    "no configuration profile interfaces",
+			fixture: "testdata/network-legacy.json",
+			ifaces: []providers.NetworkInterface{
+				{
+					Name: "eth0",
+					DHCP: providers.DHCPv4 | providers.SLAAC,
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := parseNetwork(readNetwork(t, tt.fixture))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.desc, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.ifaces) {
+			t.Errorf("%s:\nwant: %#v\n got: %#v", tt.desc, tt.ifaces, got)
+		}
+		for _, iface := range got {
+			for _, route := range iface.Routes {
+				t.Errorf("%s: unexpected route %v on %s", tt.desc, route, iface.Name)
+			}
+		}
+	}
+}
+
+func TestParseAttributes(t *testing.T) {
+	instance := Instance{ID: 42, Label: "node1", Region: "us-east", Type: "g6-standard-1"}
+	attrs := parseAttributes(instance, readNetwork(t, "testdata/network.json"))
+
+	want := map[string]string{
+		"LINODE_HOSTNAME":       "node1",
+		"LINODE_INSTANCE_ID":    "42",
+		"LINODE_HOST_UUID":      "",
+		"LINODE_REGION":         "us-east",
+		"LINODE_TYPE":           "g6-standard-1",
+		"LINODE_IPV4_PUBLIC_0":  "203.0.113.10",
+		"LINODE_IPV4_PUBLIC_1":  "203.0.113.11",
+		"LINODE_IPV4_PRIVATE_0": "192.168.128.10",
+		"LINODE_IPV6_SLAAC":     "2600:3c03::f03c:93ff:fe5a:1a2b",
+		"LINODE_IPV6_RANGE_0":   "2600:3c03:e000:1::/64",
+	}
+	if !reflect.DeepEqual(attrs, want) {
+		t.Errorf("\nwant: %v\n got: %v", want, attrs)
+	}
+	if err := Schema.Validate(attrs); err != nil {
+		t.Error(err)
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"net"
 	"reflect"
+	"strings"
 )
 
 type Metadata struct {
@@ -25,34 +26,65 @@ type Metadata struct {
 	Hostname   string
 	SshKeys    []string
 	Network    []NetworkInterface
+	Volumes    []Volume
+	Common     Common
 }
 
-type NetworkInterface struct {
-	HardwareAddress net.HardwareAddr
-	Nameservers     []net.IP
-	IPAddresses     []net.IPNet
-	Routes          []NetworkRoute
+// Common holds the values which most providers have an equivalent for. The
+// provider-neutral attributes are derived from it and the hostname, so that
+// units can use the same names on every provider.
+type Common struct {
+	PrivateIPv4 net.IP
+	PublicIPv4  net.IP
+	Region      string
+	Zone        string
+	InstanceID  string
+	// Hostname is only used for the provider-neutral attribute, for
+	// providers whose hostname is not applied to the machine.
+	Hostname string
 }
 
-type NetworkRoute struct {
-	Destination net.IPNet
-	Gateway     net.IP
-}
-
-func (i NetworkInterface) NetworkConfig() string {
-	config := fmt.Sprintf("[Match]\nMACAddress=%s\n\n[Network]\n", i.HardwareAddress)
-
-	for _, nameserver := range i.Nameservers {
-		config += fmt.Sprintf("DNS=%s\n", nameserver)
+// CommonAttributes returns the provider-neutral attributes which are known,
+// keyed like Attributes. hostname is the name applied to the machine, i.e.
+// m.Hostname after any normalisation, so that the attribute matches it.
+func (m Metadata) CommonAttributes(hostname string) map[string]string {
+	attrs := map[string]string{}
+	set := func(key, value string) {
+		if value != "" {
+			attrs[key] = value
+		}
 	}
-	for _, addr := range i.IPAddresses {
-		config += fmt.Sprintf("\n[Address]\nAddress=%s\n", addr.String())
+
+	set("CUSTOM_PRIVATE_IPV4", String(m.Common.PrivateIPv4))
+	set("CUSTOM_PUBLIC_IPV4", String(m.Common.PublicIPv4))
+	if hostname != "" {
+		set("HOSTNAME", hostname)
+	} else {
+		set("HOSTNAME", m.Common.Hostname)
 	}
-	for _, route := range i.Routes {
-		config += fmt.Sprintf("\n[Route]\nDestination=%s\nGateway=%s\n", route.Destination.String(), route.Gateway)
+	set("REGION", m.Common.Region)
+	set("ZONE", m.Common.Zone)
+	set("INSTANCE_ID", m.Common.InstanceID)
+
+	return attrs
+}
+
+// RegionOfZone returns the region of a zone which is named after it, e.g.
+// "us-east1" for "us-east1-b", or the zone itself if it has no suffix.
+func RegionOfZone(zone string) string {
+	if i := strings.LastIndex(zone, "-"); i > 0 {
+		return zone[:i]
 	}
+	return zone
+}
 
-	return config
+// Volume describes an iSCSI block volume attached to the machine.
+type Volume struct {
+	Name      string   `json:"name"`
+	Initiator string   `json:"initiator,omitempty"`
+	Target    string   `json:"target"`
+	Portals   []net.IP `json:"portals"`
+	Capacity  string   `json:"capacity,omitempty"`
 }
 
 func String(s fmt.Stringer) string {
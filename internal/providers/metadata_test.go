@@ -0,0 +1,60 @@
+package providers
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestCommonAttributes(t *testing.T) {
+	m := Metadata{
+		Hostname: "node1",
+		Common: Common{
+			PrivateIPv4: net.ParseIP("10.0.0.5"),
+			Region:      "us-east-1",
+			InstanceID:  "i-0123456789",
+		},
+	}
+
+	expected := map[string]string{
+		"CUSTOM_PRIVATE_IPV4": "10.0.0.5",
+		"HOSTNAME":            "node1",
+		"REGION":              "us-east-1",
+		"INSTANCE_ID":         "i-0123456789",
+	}
+	if attrs := m.CommonAttributes(m.Hostname); !reflect.DeepEqual(attrs, expected) {
+		t.Errorf("bad attributes: want %v, got %v", expected, attrs)
+	}
+}
+
+func TestCommonHostname(t *testing.T) {
+	m := Metadata{Common: Common{Hostname: "node1.novalocal"}}
+	if hostname := m.CommonAttributes("")["HOSTNAME"]; hostname != "node1.novalocal" {
+		t.Errorf("bad hostname attribute: want %q, got %q", "node1.novalocal", hostname)
+	}
+
+	// the name applied to the machine wins over the provider's
+	m.Hostname = "Node1.Example.com"
+	if hostname := m.CommonAttributes("node1")["HOSTNAME"]; hostname != "node1" {
+		t.Errorf("bad hostname attribute: want %q, got %q", "node1", hostname)
+	}
+}
+
+func TestRegionOfZone(t *testing.T) {
+	tests := []struct {
+		zone   string
+		region string
+	}{
+		{zone: "us-east1-b", region: "us-east1"},
+		{zone: "europe-west4-a", region: "europe-west4"},
+		{zone: "us-south-1", region: "us-south"},
+		{zone: "zone1", region: "zone1"},
+		{zone: "", region: ""},
+	}
+
+	for _, tt := range tests {
+		if region := RegionOfZone(tt.zone); region != tt.region {
+			t.Errorf("bad region for %q: want %q, got %q", tt.zone, tt.region, region)
+		}
+	}
+}
@@ -0,0 +1,262 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package netconfig parses cloud-init network-config documents, in either
+// the version 1 or version 2 (netplan) format, into network interfaces.
+package netconfig
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/coreos/coreos-metadata/internal/providers"
+
+	"gopkg.in/yaml.v2"
+)
+
+// device is the format-independent description of an interface.
+type device struct {
+	kind        string // physical, bond, vlan or bridge
+	name        string
+	mac         string
+	mtu         int
+	members     []string
+	vlanID      int
+	vlanLink    string
+	params      map[string]string
+	dhcp4       bool
+	dhcp6       bool
+	slaac       bool
+	addresses   []string
+	gateways    []string
+	nameservers []string
+	search      []string
+	routes      []route
+}
+
+type route struct {
+	to     string
+	via    string
+	metric int
+	scope  string
+}
+
+// Parse reads a network-config document. The document may also be wrapped
+// in a top-level "network" key, as some datasources do.
+func Parse(data []byte) ([]providers.NetworkInterface, error) {
+	var header struct {
+		Version int                    `yaml:"version"`
+		Network map[string]interface{} `yaml:"network"`
+	}
+	if err := yaml.Unmarshal(data, &header); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal network-config: %v", err)
+	}
+
+	if header.Version == 0 && header.Network != nil {
+		inner, err := yaml.Marshal(header.Network)
+		if err != nil {
+			return nil, err
+		}
+		return Parse(inner)
+	}
+
+	var devices []device
+	var err error
+	switch header.Version {
+	case 1:
+		devices, err = parseV1(data)
+	case 2:
+		devices, err = parseV2(data)
+	default:
+		return nil, fmt.Errorf("unsupported network-config version %d", header.Version)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return toInterfaces(devices)
+}
+
+// toInterfaces converts devices to interfaces. Physical devices without any
+// configuration are left to the system defaults.
+func toInterfaces(devices []device) ([]providers.NetworkInterface, error) {
+	var ifaces []providers.NetworkInterface
+	for _, dev := range devices {
+		iface := providers.NetworkInterface{
+			Name:          dev.name,
+			MTU:           dev.mtu,
+			SearchDomains: dev.search,
+		}
+
+		switch dev.kind {
+		case "bond":
+			iface.Kind = providers.Bond
+			iface.Members = dev.members
+			iface.BondMode = dev.params["bond-mode"]
+			if iface.BondMode == "" {
+				iface.BondMode = dev.params["mode"]
+			}
+		case "bridge":
+			iface.Kind = providers.Bridge
+			iface.Members = dev.members
+		case "vlan":
+			iface.Kind = providers.VLAN
+			iface.Members = []string{dev.vlanLink}
+			iface.VLANID = dev.vlanID
+		}
+
+		if dev.dhcp4 {
+			iface.DHCP |= providers.DHCPv4
+		}
+		if dev.dhcp6 {
+			iface.DHCP |= providers.DHCPv6
+		}
+		if dev.slaac {
+			iface.DHCP |= providers.SLAAC
+		}
+
+		if dev.mac != "" {
+			mac, err := net.ParseMAC(dev.mac)
+			if err != nil {
+				return nil, fmt.Errorf("could not parse %q as MAC address", dev.mac)
+			}
+			iface.HardwareAddress = mac
+		}
+
+		for _, server := range dev.nameservers {
+			addr := net.ParseIP(server)
+			if addr == nil {
+				return nil, fmt.Errorf("could not parse %q as IP address", server)
+			}
+			iface.Nameservers = append(iface.Nameservers, addr)
+		}
+
+		for _, address := range dev.addresses {
+			ip, network, err := net.ParseCIDR(address)
+			if err != nil {
+				return nil, fmt.Errorf("could not parse %q as IP address", address)
+			}
+			iface.IPAddresses = append(iface.IPAddresses, net.IPNet{
+				IP:   ip,
+				Mask: network.Mask,
+			})
+		}
+
+		for _, gateway := range dev.gateways {
+			r, err := defaultRoute(gateway)
+			if err != nil {
+				return nil, err
+			}
+			iface.Routes = append(iface.Routes, r)
+		}
+
+		for _, r := range dev.routes {
+			nr, err := parseRoute(r)
+			if err != nil {
+				return nil, err
+			}
+			iface.Routes = append(iface.Routes, nr)
+		}
+
+		if iface.Kind == providers.Physical && len(iface.IPAddresses) == 0 && iface.DHCP == 0 && iface.MTU == 0 {
+			continue
+		}
+
+		ifaces = append(ifaces, iface)
+	}
+
+	return ifaces, nil
+}
+
+func defaultRoute(gateway string) (providers.NetworkRoute, error) {
+	addr := net.ParseIP(gateway)
+	if addr == nil {
+		return providers.NetworkRoute{}, fmt.Errorf("could not parse %q as gateway address", gateway)
+	}
+
+	zero := net.IPv4zero
+	if addr.To4() == nil {
+		zero = net.IPv6zero
+	}
+
+	return providers.NetworkRoute{
+		Destination: net.IPNet{
+			IP:   zero,
+			Mask: net.IPMask(zero),
+		},
+		Gateway: addr,
+	}, nil
+}
+
+func parseRoute(r route) (providers.NetworkRoute, error) {
+	if r.to == "default" || r.to == "0.0.0.0/0" || r.to == "::/0" {
+		nr, err := defaultRoute(r.via)
+		nr.Metric, nr.Scope = r.metric, r.scope
+		return nr, err
+	}
+
+	_, destination, err := net.ParseCIDR(r.to)
+	if err != nil {
+		return providers.NetworkRoute{}, fmt.Errorf("could not parse %q as route destination", r.to)
+	}
+
+	var gateway net.IP
+	if r.via != "" {
+		if gateway = net.ParseIP(r.via); gateway == nil {
+			return providers.NetworkRoute{}, fmt.Errorf("could not parse %q as gateway address", r.via)
+		}
+	}
+
+	return providers.NetworkRoute{
+		Destination: *destination,
+		Gateway:     gateway,
+		Metric:      r.metric,
+		Scope:       r.scope,
+	}, nil
+}
+
+// cidr joins an address with a dotted-quad netmask or prefix length, unless
+// the address already carries a prefix length.
+func cidr(address, netmask string) (string, error) {
+	if strings.Contains(address, "/") || netmask == "" {
+		if !strings.Contains(address, "/") {
+			if ip := net.ParseIP(address); ip != nil && ip.To4() == nil {
+				return address + "/64", nil
+			}
+			return address + "/32", nil
+		}
+		return address, nil
+	}
+
+	if mask := net.ParseIP(netmask); mask != nil && mask.To4() != nil {
+		ones, bits := net.IPMask(mask.To4()).Size()
+		if bits == 0 {
+			return "", fmt.Errorf("could not parse %q as netmask", netmask)
+		}
+		return fmt.Sprintf("%s/%d", address, ones), nil
+	}
+
+	return fmt.Sprintf("%s/%s", address, netmask), nil
+}
+
+// onLink reports whether ip is inside one of the device's addresses.
+func (d device) onLink(ip net.IP) bool {
+	for _, address := range d.addresses {
+		if _, network, err := net.ParseCIDR(address); err == nil && network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,249 @@
+package netconfig
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/coreos/coreos-metadata/internal/providers"
+)
+
+func mustParseMAC(s string) net.HardwareAddr {
+	mac, err := net.ParseMAC(s)
+	if err != nil {
+		panic(err)
+	}
+	return mac
+}
+
+func TestParse(t *testing.T) {
+	defaultV4 := net.IPNet{IP: net.IPv4zero, Mask: net.IPMask(net.IPv4zero)}
+	defaultV6 := net.IPNet{IP: net.IPv6zero, Mask: net.IPMask(net.IPv6zero)}
+
+	tests := []struct {
+		desc   string
+		config string
+		ifaces []providers.NetworkInterface
+		err    bool
+	}{
+		{
+			desc: "v1 static with global nameserver and route",
+			config: `
+version: 1
+config:
+  - type: physical
+    name: eth0
+    mac_address: "52:54:00:12:34:00"
+    subnets:
+      - type: static
+        address: 192.168.1.10
+        netmask: 255.255.255.0
+        gateway: 192.168.1.1
+      - type: static6
+        address: 2001:db8::10/64
+        gateway: 2001:db8::1
+  - type: physical
+    name: eth1
+    subnets:
+      - type: dhcp
+  - type: nameserver
+    address: [192.168.1.2]
+    search: [example.com]
+  - type: route
+    destination: 10.0.0.0/8
+    gateway: 192.168.1.254
+`,
+			ifaces: []providers.NetworkInterface{
+				{
+					Name:            "eth0",
+					HardwareAddress: mustParseMAC("52:54:00:12:34:00"),
+					Nameservers:     []net.IP{net.ParseIP("192.168.1.2")},
+					SearchDomains:   []string{"example.com"},
+					IPAddresses: []net.IPNet{
+						{IP: net.ParseIP("192.168.1.10"), Mask: net.CIDRMask(24, 32)},
+						{IP: net.ParseIP("2001:db8::10"), Mask: net.CIDRMask(64, 128)},
+					},
+					Routes: []providers.NetworkRoute{
+						{Destination: defaultV4, Gateway: net.ParseIP("192.168.1.1")},
+						{Destination: defaultV6, Gateway: net.ParseIP("2001:db8::1")},
+						{
+							Destination: net.IPNet{IP: net.IPv4(10, 0, 0, 0).To4(), Mask: net.CIDRMask(8, 32)},
+							Gateway:     net.ParseIP("192.168.1.254"),
+						},
+					},
+				},
+				{
+					Name:          "eth1",
+					DHCP:          providers.DHCPv4,
+					Nameservers:   []net.IP{net.ParseIP("192.168.1.2")},
+					SearchDomains: []string{"example.com"},
+				},
+			},
+		},
+		{
+			desc: "v1 bond and vlan",
+			config: `
+version: 1
+config:
+  - type: physical
+    name: eth0
+  - type: physical
+    name: eth1
+  - type: bond
+    name: bond0
+    mtu: 9000
+    bond_interfaces: [eth0, eth1]
+    params:
+      bond-mode: active-backup
+  - type: vlan
+    name: bond0.100
+    vlan_link: bond0
+    vlan_id: 100
+    subnets:
+      - type: static
+        address: 10.100.0.5/24
+`,
+			ifaces: []providers.NetworkInterface{
+				{
+					Name:     "bond0",
+					MTU:      9000,
+					Kind:     providers.Bond,
+					Members:  []string{"eth0", "eth1"},
+					BondMode: "active-backup",
+				},
+				{
+					Name:    "bond0.100",
+					Kind:    providers.VLAN,
+					Members: []string{"bond0"},
+					VLANID:  100,
+					IPAddresses: []net.IPNet{
+						{IP: net.ParseIP("10.100.0.5"), Mask: net.CIDRMask(24, 32)},
+					},
+				},
+			},
+		},
+		{
+			desc: "v2 wrapped in network key",
+			config: `
+network:
+  version: 2
+  ethernets:
+    id0:
+      match:
+        macaddress: "52:54:00:12:34:00"
+      set-name: eth0
+      addresses: [192.168.1.10/24]
+      gateway4: 192.168.1.1
+      nameservers:
+        addresses: [192.168.1.2]
+      routes:
+        - to: 172.16.0.0/12
+          via: 192.168.1.254
+          metric: 100
+    id1:
+      dhcp4: true
+`,
+			ifaces: []providers.NetworkInterface{
+				{
+					Name:            "eth0",
+					HardwareAddress: mustParseMAC("52:54:00:12:34:00"),
+					Nameservers:     []net.IP{net.ParseIP("192.168.1.2")},
+					IPAddresses: []net.IPNet{
+						{IP: net.ParseIP("192.168.1.10"), Mask: net.CIDRMask(24, 32)},
+					},
+					Routes: []providers.NetworkRoute{
+						{Destination: defaultV4, Gateway: net.ParseIP("192.168.1.1")},
+						{
+							Destination: net.IPNet{IP: net.IPv4(172, 16, 0, 0).To4(), Mask: net.CIDRMask(12, 32)},
+							Gateway:     net.ParseIP("192.168.1.254"),
+							Metric:      100,
+						},
+					},
+				},
+				{
+					Name: "id1",
+					DHCP: providers.DHCPv4,
+				},
+			},
+		},
+		{
+			desc: "v2 bond of ethernets matched by MAC address",
+			config: `
+version: 2
+ethernets:
+  nic0:
+    match:
+      macaddress: "52:54:00:12:34:00"
+  nic1:
+    match:
+      macaddress: "52:54:00:12:34:01"
+    mtu: 9000
+bonds:
+  bond0:
+    interfaces: [nic0, nic1]
+    parameters:
+      mode: active-backup
+vlans:
+  vlan100:
+    id: 100
+    link: nic1
+    addresses: [10.100.0.5/24]
+`,
+			ifaces: []providers.NetworkInterface{
+				{
+					HardwareAddress: mustParseMAC("52:54:00:12:34:01"),
+					MTU:             9000,
+				},
+				{
+					Name:     "bond0",
+					Kind:     providers.Bond,
+					Members:  []string{"52:54:00:12:34:00", "52:54:00:12:34:01"},
+					BondMode: "active-backup",
+				},
+				{
+					Name:    "vlan100",
+					Kind:    providers.VLAN,
+					Members: []string{"52:54:00:12:34:01"},
+					VLANID:  100,
+					IPAddresses: []net.IPNet{
+						{IP: net.ParseIP("10.100.0.5"), Mask: net.CIDRMask(24, 32)},
+					},
+				},
+			},
+		},
+		{
+			desc:   "unsupported version",
+			config: "version: 3\n",
+			err:    true,
+		},
+		{
+			desc: "unknown subnet type",
+			config: `
+version: 1
+config:
+  - type: physical
+    name: eth0
+    subnets:
+      - type: carrier-pigeon
+`,
+			err: true,
+		},
+	}
+
+	for _, tt := range tests {
+		ifaces, err := Parse([]byte(tt.config))
+		if tt.err {
+			if err == nil {
+				t.Errorf("%s: expected an error", tt.desc)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.desc, err)
+			continue
+		}
+		if !reflect.DeepEqual(ifaces, tt.ifaces) {
+			t.Errorf("%s:\nwant: %#v\n got: %#v", tt.desc, tt.ifaces, ifaces)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netconfig
+
+import (
+	"fmt"
+	"net"
+
+	"gopkg.in/yaml.v2"
+)
+
+type v1Route struct {
+	Network     string `yaml:"network"`
+	Destination string `yaml:"destination"`
+	Netmask     string `yaml:"netmask"`
+	Gateway     string `yaml:"gateway"`
+	Metric      int    `yaml:"metric"`
+}
+
+type v1Subnet struct {
+	Type           string    `yaml:"type"`
+	Address        string    `yaml:"address"`
+	Netmask        string    `yaml:"netmask"`
+	Gateway        string    `yaml:"gateway"`
+	DNSNameservers []string  `yaml:"dns_nameservers"`
+	DNSSearch      []string  `yaml:"dns_search"`
+	Routes         []v1Route `yaml:"routes"`
+}
+
+type v1Entry struct {
+	Type             string                 `yaml:"type"`
+	Name             string                 `yaml:"name"`
+	MACAddress       string                 `yaml:"mac_address"`
+	MTU              int                    `yaml:"mtu"`
+	Subnets          []v1Subnet             `yaml:"subnets"`
+	BondInterfaces   []string               `yaml:"bond_interfaces"`
+	BridgeInterfaces []string               `yaml:"bridge_interfaces"`
+	VlanLink         string                 `yaml:"vlan_link"`
+	VlanID           int                    `yaml:"vlan_id"`
+	Params           map[string]interface{} `yaml:"params"`
+
+	// nameserver and route entries
+	Address     interface{} `yaml:"address"`
+	Search      interface{} `yaml:"search"`
+	Destination string      `yaml:"destination"`
+	Netmask     string      `yaml:"netmask"`
+	Network     string      `yaml:"network"`
+	Gateway     string      `yaml:"gateway"`
+	Metric      int         `yaml:"metric"`
+}
+
+func parseV1(data []byte) ([]device, error) {
+	var config struct {
+		Config []v1Entry `yaml:"config"`
+	}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal network-config: %v", err)
+	}
+
+	var devices []device
+	var nameservers, search []string
+	var routes []route
+
+	for _, entry := range config.Config {
+		switch entry.Type {
+		case "physical", "bond", "bridge", "vlan":
+			dev, err := parseV1Device(entry)
+			if err != nil {
+				return nil, err
+			}
+			devices = append(devices, dev)
+		case "nameserver":
+			nameservers = append(nameservers, stringList(entry.Address)...)
+			search = append(search, stringList(entry.Search)...)
+		case "route":
+			r, err := parseV1Route(v1Route{
+				Network:     entry.Network,
+				Destination: entry.Destination,
+				Netmask:     entry.Netmask,
+				Gateway:     entry.Gateway,
+				Metric:      entry.Metric,
+			})
+			if err != nil {
+				return nil, err
+			}
+			routes = append(routes, r)
+		default:
+			return nil, fmt.Errorf("unsupported network-config entry type %q", entry.Type)
+		}
+	}
+
+	for i := range devices {
+		if len(devices[i].nameservers) == 0 {
+			devices[i].nameservers = nameservers
+		}
+		if len(devices[i].search) == 0 {
+			devices[i].search = search
+		}
+	}
+
+	// Global routes are attached to the device whose subnet contains the
+	// gateway.
+	for _, r := range routes {
+		attached := false
+		for i := range devices {
+			if devices[i].onLink(net.ParseIP(r.via)) {
+				devices[i].routes = append(devices[i].routes, r)
+				attached = true
+				break
+			}
+		}
+		if !attached {
+			return nil, fmt.Errorf("no interface is on-link with gateway %q of route to %q", r.via, r.to)
+		}
+	}
+
+	return devices, nil
+}
+
+func parseV1Device(entry v1Entry) (device, error) {
+	dev := device{
+		kind:     entry.Type,
+		name:     entry.Name,
+		mac:      entry.MACAddress,
+		mtu:      entry.MTU,
+		vlanID:   entry.VlanID,
+		vlanLink: entry.VlanLink,
+		params:   stringMap(entry.Params),
+	}
+
+	switch entry.Type {
+	case "bond":
+		dev.members = entry.BondInterfaces
+	case "bridge":
+		dev.members = entry.BridgeInterfaces
+	}
+
+	for _, subnet := range entry.Subnets {
+		switch subnet.Type {
+		case "static", "static6":
+			address, err := cidr(subnet.Address, subnet.Netmask)
+			if err != nil {
+				return device{}, err
+			}
+			dev.addresses = append(dev.addresses, address)
+
+			if subnet.Gateway != "" {
+				dev.gateways = append(dev.gateways, subnet.Gateway)
+			}
+		case "dhcp", "dhcp4":
+			dev.dhcp4 = true
+		case "dhcp6", "ipv6_dhcpv6-stateful", "ipv6_dhcpv6-stateless":
+			dev.dhcp6 = true
+		case "ipv6_slaac":
+			dev.slaac = true
+		case "manual":
+		default:
+			return device{}, fmt.Errorf("unsupported subnet type %q on %q", subnet.Type, entry.Name)
+		}
+
+		dev.nameservers = append(dev.nameservers, subnet.DNSNameservers...)
+		dev.search = append(dev.search, subnet.DNSSearch...)
+
+		for _, r := range subnet.Routes {
+			parsed, err := parseV1Route(r)
+			if err != nil {
+				return device{}, err
+			}
+			dev.routes = append(dev.routes, parsed)
+		}
+	}
+
+	return dev, nil
+}
+
+func parseV1Route(r v1Route) (route, error) {
+	to := r.Network
+	if to == "" {
+		to = r.Destination
+	}
+
+	to, err := cidr(to, r.Netmask)
+	if err != nil {
+		return route{}, err
+	}
+
+	return route{
+		to:     to,
+		via:    r.Gateway,
+		metric: r.Metric,
+	}, nil
+}
+
+// stringList accepts either a single string or a list of strings.
+func stringList(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		var list []string
+		for _, item := range v {
+			list = append(list, fmt.Sprint(item))
+		}
+		return list
+	default:
+		return nil
+	}
+}
+
+func stringMap(value map[string]interface{}) map[string]string {
+	if value == nil {
+		return nil
+	}
+
+	m := make(map[string]string, len(value))
+	for k, v := range value {
+		m[k] = fmt.Sprint(v)
+	}
+	return m
+}
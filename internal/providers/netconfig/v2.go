@@ -0,0 +1,179 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netconfig
+
+import (
+	"fmt"
+	"net"
+	"sort"
+
+	"gopkg.in/yaml.v2"
+)
+
+type v2Device struct {
+	Match struct {
+		MACAddress string `yaml:"macaddress"`
+		Name       string `yaml:"name"`
+	} `yaml:"match"`
+	SetName     string   `yaml:"set-name"`
+	MACAddress  string   `yaml:"macaddress"`
+	MTU         int      `yaml:"mtu"`
+	DHCP4       bool     `yaml:"dhcp4"`
+	DHCP6       bool     `yaml:"dhcp6"`
+	AcceptRA    *bool    `yaml:"accept-ra"`
+	Addresses   []string `yaml:"addresses"`
+	Gateway4    string   `yaml:"gateway4"`
+	Gateway6    string   `yaml:"gateway6"`
+	Nameservers struct {
+		Addresses []string `yaml:"addresses"`
+		Search    []string `yaml:"search"`
+	} `yaml:"nameservers"`
+	Routes []struct {
+		To     string `yaml:"to"`
+		Via    string `yaml:"via"`
+		Metric int    `yaml:"metric"`
+		Scope  string `yaml:"scope"`
+	} `yaml:"routes"`
+
+	// bonds, bridges and vlans
+	Interfaces []string               `yaml:"interfaces"`
+	Parameters map[string]interface{} `yaml:"parameters"`
+	ID         int                    `yaml:"id"`
+	Link       string                 `yaml:"link"`
+}
+
+type v2Config struct {
+	Ethernets map[string]v2Device `yaml:"ethernets"`
+	Bonds     map[string]v2Device `yaml:"bonds"`
+	Bridges   map[string]v2Device `yaml:"bridges"`
+	Vlans     map[string]v2Device `yaml:"vlans"`
+}
+
+func parseV2(data []byte) ([]device, error) {
+	var config v2Config
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal network-config: %v", err)
+	}
+
+	// Devices refer to each other by ID, which is only the interface name
+	// when an ethernet has no match or set-name. Ethernets which are only
+	// matched by MAC address are referred to by that address.
+	names := map[string]string{}
+	refs := map[string]string{}
+	for id, eth := range config.Ethernets {
+		names[id] = v2Name(id, eth)
+		refs[id] = names[id]
+		if refs[id] == "" {
+			mac, err := net.ParseMAC(eth.Match.MACAddress)
+			if err != nil {
+				return nil, fmt.Errorf("could not parse %q as MAC address", eth.Match.MACAddress)
+			}
+			refs[id] = mac.String()
+		}
+	}
+	for _, section := range []map[string]v2Device{config.Bonds, config.Bridges, config.Vlans} {
+		for id := range section {
+			names[id] = id
+			refs[id] = id
+		}
+	}
+	resolve := func(id string) string {
+		if ref := refs[id]; ref != "" {
+			return ref
+		}
+		return id
+	}
+
+	var devices []device
+	for _, section := range []struct {
+		kind    string
+		devices map[string]v2Device
+	}{
+		{"physical", config.Ethernets},
+		{"bond", config.Bonds},
+		{"bridge", config.Bridges},
+		{"vlan", config.Vlans},
+	} {
+		var ids []string
+		for id := range section.devices {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+
+		for _, id := range ids {
+			d := section.devices[id]
+			dev := device{
+				kind:        section.kind,
+				name:        names[id],
+				mac:         d.MACAddress,
+				mtu:         d.MTU,
+				params:      stringMap(d.Parameters),
+				dhcp4:       d.DHCP4,
+				dhcp6:       d.DHCP6,
+				slaac:       d.AcceptRA != nil && *d.AcceptRA,
+				nameservers: d.Nameservers.Addresses,
+				search:      d.Nameservers.Search,
+			}
+			if section.kind == "physical" && d.Match.MACAddress != "" {
+				dev.mac = d.Match.MACAddress
+			}
+			if section.kind == "vlan" {
+				dev.vlanID = d.ID
+				dev.vlanLink = resolve(d.Link)
+			}
+			for _, member := range d.Interfaces {
+				dev.members = append(dev.members, resolve(member))
+			}
+
+			for _, address := range d.Addresses {
+				address, err := cidr(address, "")
+				if err != nil {
+					return nil, err
+				}
+				dev.addresses = append(dev.addresses, address)
+			}
+			for _, gateway := range []string{d.Gateway4, d.Gateway6} {
+				if gateway != "" {
+					dev.gateways = append(dev.gateways, gateway)
+				}
+			}
+			for _, r := range d.Routes {
+				dev.routes = append(dev.routes, route{
+					to:     r.To,
+					via:    r.Via,
+					metric: r.Metric,
+					scope:  r.Scope,
+				})
+			}
+
+			devices = append(devices, dev)
+		}
+	}
+
+	return devices, nil
+}
+
+func v2Name(id string, eth v2Device) string {
+	switch {
+	case eth.SetName != "":
+		return eth.SetName
+	case eth.Match.Name != "":
+		return eth.Match.Name
+	case eth.Match.MACAddress != "":
+		return ""
+	default:
+		return id
+	}
+}
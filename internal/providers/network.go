@@ -0,0 +1,347 @@
+// Copyright 2016 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package providers
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// DHCPMode selects the dynamic address configuration of an interface.
+type DHCPMode int
+
+const (
+	DHCPv4 DHCPMode = 1 << iota
+	DHCPv6
+	SLAAC
+)
+
+// DeviceKind is the kind of a virtual network device.
+type DeviceKind string
+
+const (
+	Physical DeviceKind = ""
+	Bond     DeviceKind = "bond"
+	VLAN     DeviceKind = "vlan"
+	Bridge   DeviceKind = "bridge"
+)
+
+type NetworkInterface struct {
+	// Name is used to match the interface when the provider does not
+	// report its hardware address. It is required for virtual devices.
+	Name            string
+	HardwareAddress net.HardwareAddr
+	MTU             int
+	DHCP            DHCPMode
+	Nameservers     []net.IP
+	SearchDomains   []string
+	IPAddresses     []net.IPNet
+	Routes          []NetworkRoute
+
+	// Role is the purpose of the interface on the provider's network, e.g.
+	// "public" or "private", and is used to give it a predictable name.
+	Role string
+
+	// Kind is set for virtual devices, which are created by networkd. For
+	// these, HardwareAddress is assigned to the device rather than matched.
+	Kind DeviceKind
+	// Members are the names of the interfaces enslaved to a bond or
+	// bridge, or the parent interface of a VLAN. Interfaces which are only
+	// matched by hardware address are referred to by that address.
+	Members  []string
+	VLANID   int
+	BondMode string
+
+	// Set by LinkMembers on the members of virtual devices.
+	master     string
+	masterKind DeviceKind
+	vlans      []string
+}
+
+type NetworkRoute struct {
+	Destination net.IPNet
+	Gateway     net.IP
+	Metric      int
+	// Scope is "global", "link" or "host"; networkd picks one if empty.
+	Scope string
+	// OnLink is set for gateways which are reachable on the link even
+	// though they lie outside of the interface's subnets.
+	OnLink bool
+}
+
+// LinkMembers binds the members of the virtual devices in ifaces to their
+// device, so that the members' network configs reference it. Members which
+// are not already described are added, matched by name or hardware address.
+func LinkMembers(ifaces []NetworkInterface) []NetworkInterface {
+	linked := append([]NetworkInterface{}, ifaces...)
+
+	find := func(name string) *NetworkInterface {
+		for i := range linked {
+			if linked[i].Name == name {
+				return &linked[i]
+			}
+		}
+		mac, err := net.ParseMAC(name)
+		if err != nil {
+			linked = append(linked, NetworkInterface{Name: name})
+			return &linked[len(linked)-1]
+		}
+		for i := range linked {
+			if linked[i].Name == "" && linked[i].HardwareAddress.String() == mac.String() {
+				return &linked[i]
+			}
+		}
+		linked = append(linked, NetworkInterface{HardwareAddress: mac})
+		return &linked[len(linked)-1]
+	}
+
+	for _, dev := range ifaces {
+		for _, member := range dev.Members {
+			switch dev.Kind {
+			case Bond, Bridge:
+				m := find(member)
+				m.master, m.masterKind = dev.Name, dev.Kind
+			case VLAN:
+				m := find(member)
+				m.vlans = append(m.vlans, dev.Name)
+			}
+		}
+	}
+
+	return linked
+}
+
+// AssignNames names each physical interface which has a role and a hardware
+// address after its role and its index among interfaces of that role, e.g.
+// "public0", updating the members of virtual devices to match.
+func AssignNames(ifaces []NetworkInterface) []NetworkInterface {
+	named := make([]NetworkInterface, len(ifaces))
+	renamed := map[string]string{}
+	counts := map[string]int{}
+
+	for i, iface := range ifaces {
+		if iface.Kind == Physical && iface.Role != "" && iface.HardwareAddress != nil {
+			name := fmt.Sprintf("%s%d", iface.Role, counts[iface.Role])
+			counts[iface.Role]++
+			if iface.Name != "" {
+				renamed[iface.Name] = name
+			}
+			iface.Name = name
+		}
+		named[i] = iface
+	}
+
+	for i := range named {
+		if len(named[i].Members) == 0 {
+			continue
+		}
+		members := make([]string, len(named[i].Members))
+		for j, member := range named[i].Members {
+			if name, ok := renamed[member]; ok {
+				member = name
+			}
+			members[j] = member
+		}
+		named[i].Members = members
+	}
+
+	return named
+}
+
+// LinkConfig returns the .link unit which names a physical interface, or an
+// empty string if the interface has no name or hardware address.
+func (i NetworkInterface) LinkConfig() string {
+	if i.Kind != Physical || i.Name == "" || i.HardwareAddress == nil {
+		return ""
+	}
+
+	return fmt.Sprintf("[Match]\nMACAddress=%s\n\n[Link]\nName=%s\n", i.HardwareAddress, i.Name)
+}
+
+func (i NetworkInterface) NetworkConfig() string {
+	var config string
+	if i.HardwareAddress != nil && i.Kind == Physical {
+		config = fmt.Sprintf("[Match]\nMACAddress=%s\n\n", i.HardwareAddress)
+	} else {
+		config = fmt.Sprintf("[Match]\nName=%s\n\n", i.Name)
+	}
+
+	if i.MTU > 0 && i.Kind == Physical {
+		config += fmt.Sprintf("[Link]\nMTUBytes=%d\n\n", i.MTU)
+	}
+
+	config += "[Network]\n"
+	if dhcp := i.DHCP.networkdValue(); dhcp != "" {
+		config += fmt.Sprintf("DHCP=%s\n", dhcp)
+	}
+	if i.DHCP&SLAAC != 0 {
+		config += "IPv6AcceptRA=yes\n"
+	}
+	for _, nameserver := range i.Nameservers {
+		config += fmt.Sprintf("DNS=%s\n", nameserver)
+	}
+	if len(i.SearchDomains) > 0 {
+		config += fmt.Sprintf("Domains=%s\n", strings.Join(i.SearchDomains, " "))
+	}
+	switch i.masterKind {
+	case Bond:
+		config += fmt.Sprintf("Bond=%s\n", i.master)
+	case Bridge:
+		config += fmt.Sprintf("Bridge=%s\n", i.master)
+	}
+	for _, vlan := range i.vlans {
+		config += fmt.Sprintf("VLAN=%s\n", vlan)
+	}
+
+	for _, addr := range i.IPAddresses {
+		config += fmt.Sprintf("\n[Address]\nAddress=%s\n", addr.String())
+	}
+	for _, route := range i.Routes {
+		config += fmt.Sprintf("\n[Route]\nDestination=%s\n", route.Destination.String())
+		if route.Gateway != nil {
+			config += fmt.Sprintf("Gateway=%s\n", route.Gateway)
+		}
+		if route.OnLink {
+			config += "GatewayOnLink=yes\n"
+		}
+		if route.Metric > 0 {
+			config += fmt.Sprintf("Metric=%d\n", route.Metric)
+		}
+		if route.Scope != "" {
+			config += fmt.Sprintf("Scope=%s\n", route.Scope)
+		}
+	}
+
+	return config
+}
+
+// The resolver reads at most this many nameservers and search domains from
+// resolv.conf.
+const (
+	maxResolvNameservers = 3
+	maxResolvDomains     = 6
+)
+
+// ResolvConf returns a resolv.conf listing the nameservers and search
+// domains of all of the interfaces, or an empty string if there are none.
+// Only as many as the resolver uses are listed.
+func ResolvConf(ifaces []NetworkInterface) string {
+	var config string
+	nameservers, domains := globalDNS(ifaces)
+	if len(nameservers) > maxResolvNameservers {
+		nameservers = nameservers[:maxResolvNameservers]
+	}
+	if len(domains) > maxResolvDomains {
+		domains = domains[:maxResolvDomains]
+	}
+	for _, nameserver := range nameservers {
+		config += fmt.Sprintf("nameserver %s\n", nameserver)
+	}
+	if len(domains) > 0 {
+		config += fmt.Sprintf("search %s\n", strings.Join(domains, " "))
+	}
+
+	return config
+}
+
+// ResolvedConfig returns a systemd-resolved resolved.conf drop-in setting
+// the nameservers and search domains of all of the interfaces as the global
+// DNS configuration, or an empty string if there are none.
+func ResolvedConfig(ifaces []NetworkInterface) string {
+	nameservers, domains := globalDNS(ifaces)
+	if len(nameservers) == 0 && len(domains) == 0 {
+		return ""
+	}
+
+	config := "[Resolve]\n"
+	if len(nameservers) > 0 {
+		var servers []string
+		for _, nameserver := range nameservers {
+			servers = append(servers, nameserver.String())
+		}
+		config += fmt.Sprintf("DNS=%s\n", strings.Join(servers, " "))
+	}
+	if len(domains) > 0 {
+		config += fmt.Sprintf("Domains=%s\n", strings.Join(domains, " "))
+	}
+
+	return config
+}
+
+// globalDNS merges the nameservers and search domains of the interfaces,
+// keeping the first occurrence of each.
+func globalDNS(ifaces []NetworkInterface) ([]net.IP, []string) {
+	var nameservers []net.IP
+	var domains []string
+	seen := map[string]bool{}
+
+	for _, iface := range ifaces {
+		for _, nameserver := range iface.Nameservers {
+			if !seen[nameserver.String()] {
+				seen[nameserver.String()] = true
+				nameservers = append(nameservers, nameserver)
+			}
+		}
+		for _, domain := range iface.SearchDomains {
+			if !seen[domain] {
+				seen[domain] = true
+				domains = append(domains, domain)
+			}
+		}
+	}
+
+	return nameservers, domains
+}
+
+// NetdevConfig returns the networkd .netdev unit which creates a virtual
+// device, or an empty string for physical interfaces.
+func (i NetworkInterface) NetdevConfig() string {
+	if i.Kind == Physical {
+		return ""
+	}
+
+	config := fmt.Sprintf("[NetDev]\nName=%s\nKind=%s\n", i.Name, i.Kind)
+	if i.HardwareAddress != nil {
+		config += fmt.Sprintf("MACAddress=%s\n", i.HardwareAddress)
+	}
+	if i.MTU > 0 {
+		config += fmt.Sprintf("MTUBytes=%d\n", i.MTU)
+	}
+
+	switch i.Kind {
+	case Bond:
+		if i.BondMode != "" {
+			config += fmt.Sprintf("\n[Bond]\nMode=%s\n", i.BondMode)
+		}
+	case VLAN:
+		config += fmt.Sprintf("\n[VLAN]\nId=%d\n", i.VLANID)
+	}
+
+	return config
+}
+
+func (m DHCPMode) networkdValue() string {
+	switch {
+	case m&DHCPv4 != 0 && m&DHCPv6 != 0:
+		return "yes"
+	case m&DHCPv4 != 0:
+		return "ipv4"
+	case m&DHCPv6 != 0:
+		return "ipv6"
+	default:
+		return ""
+	}
+}
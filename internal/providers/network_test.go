@@ -0,0 +1,193 @@
+package providers
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestNetworkConfig(t *testing.T) {
+	mac, _ := net.ParseMAC("52:54:00:12:34:00")
+
+	tests := []struct {
+		desc    string
+		iface   NetworkInterface
+		network string
+		netdev  string
+	}{
+		{
+			desc: "static",
+			iface: NetworkInterface{
+				HardwareAddress: mac,
+				Nameservers:     []net.IP{net.ParseIP("10.0.0.2")},
+				IPAddresses:     []net.IPNet{{IP: net.ParseIP("10.0.0.5"), Mask: net.CIDRMask(24, 32)}},
+				Routes: []NetworkRoute{{
+					Destination: net.IPNet{IP: net.IPv4zero, Mask: net.IPMask(net.IPv4zero)},
+					Gateway:     net.ParseIP("10.0.0.1"),
+				}},
+			},
+			network: "[Match]\nMACAddress=52:54:00:12:34:00\n\n[Network]\nDNS=10.0.0.2\n\n" +
+				"[Address]\nAddress=10.0.0.5/24\n\n[Route]\nDestination=0.0.0.0/0\nGateway=10.0.0.1\n",
+		},
+		{
+			desc: "dhcp with mtu, search domains and link route",
+			iface: NetworkInterface{
+				Name:          "eth1",
+				MTU:           9000,
+				DHCP:          DHCPv4 | SLAAC,
+				SearchDomains: []string{"example.com", "example.net"},
+				Routes: []NetworkRoute{{
+					Destination: net.IPNet{IP: net.IPv4(10, 1, 0, 0).To4(), Mask: net.CIDRMask(16, 32)},
+					Metric:      50,
+					Scope:       "link",
+				}},
+			},
+			network: "[Match]\nName=eth1\n\n[Link]\nMTUBytes=9000\n\n[Network]\nDHCP=ipv4\nIPv6AcceptRA=yes\n" +
+				"Domains=example.com example.net\n\n[Route]\nDestination=10.1.0.0/16\nMetric=50\nScope=link\n",
+		},
+		{
+			desc: "vlan",
+			iface: NetworkInterface{
+				Name:    "eth0.100",
+				Kind:    VLAN,
+				Members: []string{"eth0"},
+				VLANID:  100,
+				DHCP:    DHCPv4 | DHCPv6,
+			},
+			network: "[Match]\nName=eth0.100\n\n[Network]\nDHCP=yes\n",
+			netdev:  "[NetDev]\nName=eth0.100\nKind=vlan\n\n[VLAN]\nId=100\n",
+		},
+	}
+
+	for _, tt := range tests {
+		if network := tt.iface.NetworkConfig(); network != tt.network {
+			t.Errorf("%s network:\nwant: %q\n got: %q", tt.desc, tt.network, network)
+		}
+		if netdev := tt.iface.NetdevConfig(); netdev != tt.netdev {
+			t.Errorf("%s netdev:\nwant: %q\n got: %q", tt.desc, tt.netdev, netdev)
+		}
+	}
+}
+
+func TestLinkMembers(t *testing.T) {
+	ifaces := LinkMembers([]NetworkInterface{
+		{Name: "eth0", DHCP: DHCPv4},
+		{Name: "bond0", Kind: Bond, Members: []string{"eth0", "eth1"}, BondMode: "802.3ad", MTU: 9000},
+		{Name: "bond0.10", Kind: VLAN, Members: []string{"bond0"}, VLANID: 10},
+	})
+
+	want := map[string]string{
+		"eth0":     "[Match]\nName=eth0\n\n[Network]\nDHCP=ipv4\nBond=bond0\n",
+		"eth1":     "[Match]\nName=eth1\n\n[Network]\nBond=bond0\n",
+		"bond0":    "[Match]\nName=bond0\n\n[Network]\nVLAN=bond0.10\n",
+		"bond0.10": "[Match]\nName=bond0.10\n\n[Network]\n",
+	}
+
+	if len(ifaces) != len(want) {
+		t.Fatalf("want %d interfaces, got %d", len(want), len(ifaces))
+	}
+	for _, iface := range ifaces {
+		if network := iface.NetworkConfig(); network != want[iface.Name] {
+			t.Errorf("%s:\nwant: %q\n got: %q", iface.Name, want[iface.Name], network)
+		}
+	}
+
+	const netdev = "[NetDev]\nName=bond0\nKind=bond\nMTUBytes=9000\n\n[Bond]\nMode=802.3ad\n"
+	if got := ifaces[1].NetdevConfig(); got != netdev {
+		t.Errorf("bond0 netdev:\nwant: %q\n got: %q", netdev, got)
+	}
+
+	// members which are only matched by hardware address
+	mac0, _ := net.ParseMAC("52:54:00:12:34:00")
+	mac1, _ := net.ParseMAC("52:54:00:12:34:01")
+	ifaces = LinkMembers([]NetworkInterface{
+		{HardwareAddress: mac1, MTU: 9000},
+		{Name: "bond0", Kind: Bond, Members: []string{mac0.String(), mac1.String()}},
+	})
+	want = map[string]string{
+		mac1.String(): "[Match]\nMACAddress=52:54:00:12:34:01\n\n[Link]\nMTUBytes=9000\n\n[Network]\nBond=bond0\n",
+		"bond0":       "[Match]\nName=bond0\n\n[Network]\n",
+		mac0.String(): "[Match]\nMACAddress=52:54:00:12:34:00\n\n[Network]\nBond=bond0\n",
+	}
+	if len(ifaces) != len(want) {
+		t.Fatalf("want %d interfaces, got %d", len(want), len(ifaces))
+	}
+	for _, iface := range ifaces {
+		key := iface.Name
+		if key == "" {
+			key = iface.HardwareAddress.String()
+		}
+		if network := iface.NetworkConfig(); network != want[key] {
+			t.Errorf("%s:\nwant: %q\n got: %q", key, want[key], network)
+		}
+	}
+}
+
+func TestAssignNames(t *testing.T) {
+	pub, _ := net.ParseMAC("52:54:00:12:34:00")
+	priv, _ := net.ParseMAC("52:54:00:12:34:01")
+
+	ifaces := AssignNames([]NetworkInterface{
+		{Name: "eth0", HardwareAddress: pub, Role: "public"},
+		{Name: "eth1", HardwareAddress: priv, Role: "private"},
+		{Name: "eth2"},
+		{Name: "bond0", Kind: Bond, Role: "public", Members: []string{"eth0", "eth2"}},
+	})
+
+	names := []string{"public0", "private0", "eth2", "bond0"}
+	for i, iface := range ifaces {
+		if iface.Name != names[i] {
+			t.Errorf("bad name for interface %d: want %q, got %q", i, names[i], iface.Name)
+		}
+	}
+	if members := ifaces[3].Members; !reflect.DeepEqual(members, []string{"public0", "eth2"}) {
+		t.Errorf("bad bond members: got %v", members)
+	}
+
+	link := "[Match]\nMACAddress=52:54:00:12:34:00\n\n[Link]\nName=public0\n"
+	if config := ifaces[0].LinkConfig(); config != link {
+		t.Errorf("bad link config: want %q, got %q", link, config)
+	}
+	if config := ifaces[2].LinkConfig(); config != "" {
+		t.Errorf("unexpected link config for interface without MAC: %q", config)
+	}
+}
+
+func TestDNSConfig(t *testing.T) {
+	ifaces := []NetworkInterface{
+		{
+			Nameservers:   []net.IP{net.ParseIP("10.0.0.2"), net.ParseIP("10.0.0.3")},
+			SearchDomains: []string{"example.com"},
+		},
+		{
+			Nameservers:   []net.IP{net.ParseIP("10.0.0.2")},
+			SearchDomains: []string{"example.net", "example.com"},
+		},
+	}
+
+	expected := "nameserver 10.0.0.2\nnameserver 10.0.0.3\nsearch example.com example.net\n"
+	if config := ResolvConf(ifaces); config != expected {
+		t.Errorf("bad resolv.conf: want %q, got %q", expected, config)
+	}
+	if config := ResolvConf([]NetworkInterface{{Name: "eth0"}}); config != "" {
+		t.Errorf("unexpected resolv.conf: %q", config)
+	}
+
+	expected = "[Resolve]\nDNS=10.0.0.2 10.0.0.3\nDomains=example.com example.net\n"
+	if config := ResolvedConfig(ifaces); config != expected {
+		t.Errorf("bad resolved.conf drop-in: want %q, got %q", expected, config)
+	}
+	if config := ResolvedConfig([]NetworkInterface{{Name: "eth0"}}); config != "" {
+		t.Errorf("unexpected resolved.conf drop-in: %q", config)
+	}
+
+	many := []NetworkInterface{{
+		Nameservers:   []net.IP{net.ParseIP("10.0.0.1"), net.ParseIP("10.0.0.2"), net.ParseIP("10.0.0.3"), net.ParseIP("10.0.0.4")},
+		SearchDomains: []string{"a.example", "b.example", "c.example", "d.example", "e.example", "f.example", "g.example"},
+	}}
+	expected = "nameserver 10.0.0.1\nnameserver 10.0.0.2\nnameserver 10.0.0.3\n" +
+		"search a.example b.example c.example d.example e.example f.example\n"
+	if config := ResolvConf(many); config != expected {
+		t.Errorf("bad capped resolv.conf: want %q, got %q", expected, config)
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package nocloud reads a cloud-init NoCloud seed, consisting of the
+// meta-data, user-data and network-config files, from a filesystem labelled
+// "cidata", a local directory or an HTTP server.
+package nocloud
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/coreos/coreos-metadata/internal/mount"
+	"github.com/coreos/coreos-metadata/internal/providers"
+	"github.com/coreos/coreos-metadata/internal/providers/cloudinit"
+	"github.com/coreos/coreos-metadata/internal/providers/netconfig"
+	"github.com/coreos/coreos-metadata/internal/retry"
+
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	labels      = []string{"cidata", "CIDATA"}
+	filesystems = []string{"iso9660", "vfat"}
+)
+
+type MetaData struct {
+	InstanceID    string      `yaml:"instance-id"`
+	LocalHostname string      `yaml:"local-hostname"`
+	PublicKeys    interface{} `yaml:"public-keys"`
+}
+
+// Schema describes the attributes produced by FetchMetadata.
+var Schema = providers.Schema{
+	{Name: "NOCLOUD_INSTANCE_ID", Description: "The instance ID from meta-data", Type: providers.TypeString, Optional: true},
+	{Name: "NOCLOUD_HOSTNAME", Description: "The hostname from meta-data", Type: providers.TypeString, Optional: true},
+}
+
+// FetchMetadata reads the seed from the filesystem labelled "cidata".
+func FetchMetadata() (providers.Metadata, error) {
+	return FetchMetadataFrom("")
+}
+
+// FetchMetadataFrom reads the seed from the given directory or http(s) URL.
+// The filesystem labelled "cidata" is used when seed is empty.
+func FetchMetadataFrom(seed string) (providers.Metadata, error) {
+	var read func(name string) ([]byte, error)
+
+	switch {
+	case seed == "":
+		dir, cleanup, err := mount.ByLabel(labels, filesystems)
+		if err != nil {
+			return providers.Metadata{}, err
+		}
+		defer cleanup()
+		read = directoryReader(dir)
+	case strings.HasPrefix(seed, "http://"), strings.HasPrefix(seed, "https://"):
+		read = httpReader(seed)
+	default:
+		read = directoryReader(strings.TrimPrefix(seed, "file://"))
+	}
+
+	return fetchMetadata(read)
+}
+
+// ParseDatasource returns the seed location from the value of a
+// "ds=nocloud;s=<seed>" kernel argument, and whether the value selects the
+// NoCloud datasource at all.
+func ParseDatasource(ds string) (seed string, ok bool) {
+	parts := strings.Split(ds, ";")
+	if parts[0] != "nocloud" && parts[0] != "nocloud-net" {
+		return "", false
+	}
+
+	for _, part := range parts[1:] {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) == 2 && (kv[0] == "s" || kv[0] == "seedfrom") {
+			seed = kv[1]
+		}
+	}
+
+	return seed, true
+}
+
+func fetchMetadata(read func(name string) ([]byte, error)) (providers.Metadata, error) {
+	body, err := read("meta-data")
+	if err != nil {
+		return providers.Metadata{}, fmt.Errorf("failed to read meta-data: %v", err)
+	}
+	if body == nil {
+		return providers.Metadata{}, fmt.Errorf("seed does not contain meta-data")
+	}
+
+	var meta MetaData
+	if err := yaml.Unmarshal(body, &meta); err != nil {
+		return providers.Metadata{}, fmt.Errorf("failed to unmarshal meta-data: %v", err)
+	}
+
+	body, err = read("user-data")
+	if err != nil {
+		return providers.Metadata{}, fmt.Errorf("failed to read user-data: %v", err)
+	}
+
+	user, err := cloudinit.ParseUserData(body)
+	if err != nil {
+		return providers.Metadata{}, fmt.Errorf("failed to unmarshal user-data: %v", err)
+	}
+
+	body, err = read("network-config")
+	if err != nil {
+		return providers.Metadata{}, fmt.Errorf("failed to read network-config: %v", err)
+	}
+
+	var network []providers.NetworkInterface
+	if body != nil {
+		network, err = netconfig.Parse(body)
+		if err != nil {
+			return providers.Metadata{}, fmt.Errorf("failed to parse network-config: %v", err)
+		}
+	}
+
+	hostname := cloudinit.Hostname(meta.LocalHostname, user)
+
+	keys, err := cloudinit.ParsePublicKeys(meta.PublicKeys)
+	if err != nil {
+		return providers.Metadata{}, err
+	}
+
+	return providers.Metadata{
+		Attributes: map[string]string{
+			"NOCLOUD_INSTANCE_ID": meta.InstanceID,
+			"NOCLOUD_HOSTNAME":    hostname,
+		},
+		Hostname: hostname,
+		Network:  network,
+		SshKeys:  append(keys, user.SshAuthorizedKeys...),
+		Common:   providers.Common{InstanceID: meta.InstanceID},
+	}, nil
+}
+
+func directoryReader(dir string) func(name string) ([]byte, error) {
+	return func(name string) ([]byte, error) {
+		body, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return body, err
+	}
+}
+
+func httpReader(seed string) func(name string) ([]byte, error) {
+	if !strings.HasSuffix(seed, "/") {
+		seed += "/"
+	}
+
+	client := retry.Client{
+		InitialBackoff: time.Second,
+		MaxBackoff:     time.Second * 5,
+		MaxAttempts:    10,
+	}
+
+	return func(name string) ([]byte, error) {
+		return client.Get(seed + name)
+	}
+}
@@ -0,0 +1,73 @@
+package nocloud
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/coreos/coreos-metadata/internal/providers"
+)
+
+func TestFetchMetadataFromDirectory(t *testing.T) {
+	mac, _ := net.ParseMAC("52:54:00:12:34:00")
+	want := providers.Metadata{
+		Attributes: map[string]string{
+			"NOCLOUD_INSTANCE_ID": "iid-local01",
+			"NOCLOUD_HOSTNAME":    "cloudimg.example.com",
+		},
+		Hostname: "cloudimg.example.com",
+		SshKeys: []string{
+			"ssh-rsa AAAAB3NzaC1yc2EAAAADAQABAAABAQC0g+ZTxC7weoIJLUafOgrm+h core@meta",
+			"ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIGxi core@user",
+		},
+		Network: []providers.NetworkInterface{
+			{
+				Name:            "eth0",
+				HardwareAddress: mac,
+				Nameservers:     []net.IP{net.ParseIP("192.168.1.1")},
+				IPAddresses: []net.IPNet{
+					{IP: net.ParseIP("192.168.1.10"), Mask: net.IPv4Mask(255, 255, 255, 0)},
+				},
+				Routes: []providers.NetworkRoute{
+					{
+						Destination: net.IPNet{IP: net.IPv4zero, Mask: net.IPMask(net.IPv4zero)},
+						Gateway:     net.ParseIP("192.168.1.1"),
+					},
+				},
+			},
+		},
+		Common: providers.Common{InstanceID: "iid-local01"},
+	}
+
+	got, err := FetchMetadataFrom("testdata/seed")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("\nwant: %#v\n got: %#v", want, got)
+	}
+	if err := Schema.Validate(got.Attributes); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestParseDatasource(t *testing.T) {
+	tests := []struct {
+		ds   string
+		seed string
+		ok   bool
+	}{
+		{ds: "nocloud", seed: "", ok: true},
+		{ds: "nocloud;s=http://10.0.2.2:8000/", seed: "http://10.0.2.2:8000/", ok: true},
+		{ds: "nocloud-net;seedfrom=file:///var/seed/", seed: "file:///var/seed/", ok: true},
+		{ds: "ec2", seed: "", ok: false},
+		{ds: "", seed: "", ok: false},
+	}
+
+	for _, tt := range tests {
+		seed, ok := ParseDatasource(tt.ds)
+		if seed != tt.seed || ok != tt.ok {
+			t.Errorf("%q:\nwant: %q, %t\n got: %q, %t", tt.ds, tt.seed, tt.ok, seed, ok)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oci
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/coreos/coreos-metadata/internal/providers"
+	"github.com/coreos/coreos-metadata/internal/retry"
+)
+
+const (
+	metadataEndpoint = "http://169.254.169.254/opc/v2/"
+)
+
+type Instance struct {
+	AvailabilityDomain  string `json:"availabilityDomain"`
+	FaultDomain         string `json:"faultDomain"`
+	CompartmentID       string `json:"compartmentId"`
+	Hostname            string `json:"hostname"`
+	ID                  string `json:"id"`
+	Region              string `json:"region"`
+	CanonicalRegionName string `json:"canonicalRegionName"`
+	Shape               string `json:"shape"`
+	Metadata            struct {
+		SshAuthorizedKeys string `json:"ssh_authorized_keys"`
+	} `json:"metadata"`
+}
+
+type VNIC struct {
+	ID              string `json:"vnicId"`
+	PrivateIP       string `json:"privateIp"`
+	MAC             string `json:"macAddr"`
+	VirtualRouterIP string `json:"virtualRouterIp"`
+	SubnetCidrBlock string `json:"subnetCidrBlock"`
+}
+
+// Schema describes the attributes produced by FetchMetadata.
+var Schema = providers.Schema{
+	{Name: "OCI_HOSTNAME", Description: "The hostname of the instance", Type: providers.TypeString, Optional: true},
+	{Name: "OCI_INSTANCE_ID", Description: "The OCID of the instance", Type: providers.TypeString},
+	{Name: "OCI_REGION", Description: "The region of the instance", Type: providers.TypeString, Optional: true},
+	{Name: "OCI_AVAILABILITY_DOMAIN", Description: "The availability domain of the instance", Type: providers.TypeString, Optional: true},
+	{Name: "OCI_FAULT_DOMAIN", Description: "The fault domain of the instance", Type: providers.TypeString, Optional: true},
+	{Name: "OCI_SHAPE", Description: "The shape of the instance", Type: providers.TypeString, Optional: true},
+	{Name: "OCI_COMPARTMENT_ID", Description: "The OCID of the compartment of the instance", Type: providers.TypeString, Optional: true},
+	{Name: "OCI_IPV4_PRIVATE", Description: "The private IPv4 address of the nth VNIC", Type: providers.TypeIPv4, Indexed: true},
+}
+
+func FetchMetadata() (providers.Metadata, error) {
+	var instance Instance
+	if err := fetchJSON("instance/", &instance); err != nil {
+		return providers.Metadata{}, fmt.Errorf("failed to fetch instance metadata: %v", err)
+	}
+
+	var vnics []VNIC
+	if err := fetchJSON("vnics/", &vnics); err != nil {
+		return providers.Metadata{}, fmt.Errorf("failed to fetch vnic metadata: %v", err)
+	}
+
+	network, err := parseNetwork(vnics)
+	if err != nil {
+		return providers.Metadata{}, fmt.Errorf("failed to parse network config from metadata: %v", err)
+	}
+
+	return providers.Metadata{
+		Attributes: parseAttributes(instance, vnics),
+		Hostname:   instance.Hostname,
+		Network:    network,
+		SshKeys:    parseKeys(instance.Metadata.SshAuthorizedKeys),
+		Common:     parseCommon(instance, vnics),
+	}, nil
+}
+
+func fetchJSON(key string, v interface{}) error {
+	body, err := retry.Client{
+		InitialBackoff: time.Second,
+		MaxBackoff:     time.Second * 5,
+		MaxAttempts:    10,
+		Header: map[string][]string{
+			"Authorization": {"Bearer Oracle"},
+		},
+	}.Get(metadataEndpoint + key)
+	if err != nil {
+		return err
+	}
+	if body == nil {
+		return fmt.Errorf("%q not found", key)
+	}
+
+	return json.Unmarshal(body, v)
+}
+
+func parseAttributes(instance Instance, vnics []VNIC) map[string]string {
+	attrs := map[string]string{
+		"OCI_HOSTNAME":            instance.Hostname,
+		"OCI_INSTANCE_ID":         instance.ID,
+		"OCI_REGION":              regionName(instance),
+		"OCI_AVAILABILITY_DOMAIN": instance.AvailabilityDomain,
+		"OCI_FAULT_DOMAIN":        instance.FaultDomain,
+		"OCI_SHAPE":               instance.Shape,
+		"OCI_COMPARTMENT_ID":      instance.CompartmentID,
+	}
+
+	for i, vnic := range vnics {
+		attrs[fmt.Sprintf("OCI_IPV4_PRIVATE_%d", i)] =
+			providers.String(net.ParseIP(vnic.PrivateIP))
+	}
+
+	return attrs
+}
+
+func parseCommon(instance Instance, vnics []VNIC) providers.Common {
+	common := providers.Common{
+		Region:     regionName(instance),
+		Zone:       instance.AvailabilityDomain,
+		InstanceID: instance.ID,
+	}
+	if len(vnics) > 0 {
+		common.PrivateIPv4 = net.ParseIP(vnics[0].PrivateIP)
+	}
+
+	return common
+}
+
+// regionName prefers the canonical name of the region, e.g. us-ashburn-1,
+// over its short code.
+func regionName(instance Instance) string {
+	if instance.CanonicalRegionName != "" {
+		return instance.CanonicalRegionName
+	}
+	return instance.Region
+}
+
+func parseKeys(blob string) []string {
+	var keys []string
+	for _, key := range strings.Split(blob, "\n") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// parseNetwork converts the VNICs into interface configs. The first VNIC is
+// the primary one and is the only one given a default route.
+func parseNetwork(vnics []VNIC) ([]providers.NetworkInterface, error) {
+	var ifaces []providers.NetworkInterface
+	for i, vnic := range vnics {
+		mac, err := net.ParseMAC(vnic.MAC)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse %q as MAC address", vnic.MAC)
+		}
+
+		ip := net.ParseIP(vnic.PrivateIP)
+		if ip == nil {
+			return nil, fmt.Errorf("could not parse %q as IPv4 address", vnic.PrivateIP)
+		}
+
+		_, subnet, err := net.ParseCIDR(vnic.SubnetCidrBlock)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse %q as subnet", vnic.SubnetCidrBlock)
+		}
+
+		iface := providers.NetworkInterface{
+			HardwareAddress: mac,
+			IPAddresses: []net.IPNet{{
+				IP:   ip,
+				Mask: subnet.Mask,
+			}},
+		}
+
+		if i == 0 && vnic.VirtualRouterIP != "" {
+			gateway := net.ParseIP(vnic.VirtualRouterIP)
+			if gateway == nil {
+				return nil, fmt.Errorf("could not parse %q as gateway address", vnic.VirtualRouterIP)
+			}
+
+			iface.Routes = append(iface.Routes, providers.NetworkRoute{
+				Destination: net.IPNet{
+					IP:   net.IPv4zero,
+					Mask: net.IPMask(net.IPv4zero),
+				},
+				Gateway: gateway,
+			})
+		}
+
+		ifaces = append(ifaces, iface)
+	}
+
+	return ifaces, nil
+}
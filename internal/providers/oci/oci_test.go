@@ -0,0 +1,107 @@
+package oci
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/coreos/coreos-metadata/internal/providers"
+)
+
+func readFixture(t *testing.T, name string, v interface{}) {
+	body, err := ioutil.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseNetwork(t *testing.T) {
+	var vnics []VNIC
+	readFixture(t, "vnics.json", &vnics)
+
+	mac0, _ := net.ParseMAC("02:00:17:00:a1:b2")
+	mac1, _ := net.ParseMAC("02:00:17:00:c3:d4")
+	want := []providers.NetworkInterface{
+		{
+			HardwareAddress: mac0,
+			IPAddresses:     []net.IPNet{{IP: net.ParseIP("10.0.0.2"), Mask: net.CIDRMask(24, 32)}},
+			Routes: []providers.NetworkRoute{{
+				Destination: net.IPNet{IP: net.IPv4zero, Mask: net.IPMask(net.IPv4zero)},
+				Gateway:     net.ParseIP("10.0.0.1"),
+			}},
+		},
+		{
+			HardwareAddress: mac1,
+			IPAddresses:     []net.IPNet{{IP: net.ParseIP("10.0.16.5"), Mask: net.CIDRMask(20, 32)}},
+		},
+	}
+
+	got, err := parseNetwork(vnics)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("\nwant: %#v\n got: %#v", want, got)
+	}
+	if _, err := providers.ValidateNetwork(got); err != nil {
+		t.Errorf("invalid network: %v", err)
+	}
+
+	vnics[1].SubnetCidrBlock = "10.0.16.0"
+	if _, err := parseNetwork(vnics); err == nil {
+		t.Error("expected an error for a VNIC without a subnet CIDR")
+	}
+}
+
+func TestParseAttributes(t *testing.T) {
+	var instance Instance
+	var vnics []VNIC
+	readFixture(t, "instance.json", &instance)
+	readFixture(t, "vnics.json", &vnics)
+
+	want := map[string]string{
+		"OCI_HOSTNAME":            "node1",
+		"OCI_INSTANCE_ID":         "ocid1.instance.oc1.iad.anuwcljt4bhjqai",
+		"OCI_REGION":              "us-ashburn-1",
+		"OCI_AVAILABILITY_DOMAIN": "EMIr:US-ASHBURN-AD-1",
+		"OCI_FAULT_DOMAIN":        "FAULT-DOMAIN-2",
+		"OCI_SHAPE":               "VM.Standard2.1",
+		"OCI_COMPARTMENT_ID":      "ocid1.compartment.oc1..aaaaaaaa6p3ex5q",
+		"OCI_IPV4_PRIVATE_0":      "10.0.0.2",
+		"OCI_IPV4_PRIVATE_1":      "10.0.16.5",
+	}
+	got := parseAttributes(instance, vnics)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("\nwant: %v\n got: %v", want, got)
+	}
+	if err := Schema.Validate(got); err != nil {
+		t.Error(err)
+	}
+
+	keys := []string{"ssh-rsa AAAA core@example", "ssh-ed25519 AAAAC3 core@laptop"}
+	if got := parseKeys(instance.Metadata.SshAuthorizedKeys); !reflect.DeepEqual(got, keys) {
+		t.Errorf("bad keys:\nwant: %v\n got: %v", keys, got)
+	}
+}
+
+func TestRegionName(t *testing.T) {
+	tests := []struct {
+		instance Instance
+		region   string
+	}{
+		{instance: Instance{Region: "iad", CanonicalRegionName: "us-ashburn-1"}, region: "us-ashburn-1"},
+		{instance: Instance{Region: "phx"}, region: "phx"},
+		{instance: Instance{}, region: ""},
+	}
+
+	for _, tt := range tests {
+		if region := regionName(tt.instance); region != tt.region {
+			t.Errorf("bad region for %+v: want %q, got %q", tt.instance, tt.region, region)
+		}
+	}
+}
@@ -0,0 +1,308 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package opennebula reads the contextualization variables that OpenNebula
+// places in context.sh on the CONTEXT CD-ROM.
+package opennebula
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/coreos/coreos-metadata/internal/mount"
+	"github.com/coreos/coreos-metadata/internal/providers"
+)
+
+const (
+	contextFile   = "context.sh"
+	defaultMask   = "255.255.255.0"
+	defaultPrefix = 64
+)
+
+var (
+	labels      = []string{"CONTEXT"}
+	filesystems = []string{"iso9660"}
+
+	ifaceMAC = regexp.MustCompile(`^ETH(\d+)_MAC$`)
+)
+
+// Schema describes the attributes produced by FetchMetadata.
+var Schema = providers.Schema{
+	{Name: "OPENNEBULA_HOSTNAME", Description: "The hostname from the context", Type: providers.TypeString, Optional: true},
+	{Name: "OPENNEBULA_IPV4", Description: "The IPv4 address of interface ETHn", Type: providers.TypeIPv4, Optional: true, Indexed: true},
+	{Name: "OPENNEBULA_IPV6", Description: "The IPv6 address of interface ETHn", Type: providers.TypeIPv6, Optional: true, Indexed: true},
+}
+
+// FetchMetadata reads context.sh from the CONTEXT CD-ROM.
+func FetchMetadata() (providers.Metadata, error) {
+	dir, cleanup, err := mount.ByLabel(labels, filesystems)
+	if err != nil {
+		return providers.Metadata{}, err
+	}
+	defer cleanup()
+
+	return FetchMetadataFrom(filepath.Join(dir, contextFile))
+}
+
+// FetchMetadataFrom reads the given context.sh.
+func FetchMetadataFrom(path string) (providers.Metadata, error) {
+	body, err := ioutil.ReadFile(path)
+	if err != nil {
+		return providers.Metadata{}, fmt.Errorf("failed to read context: %v", err)
+	}
+
+	vars, err := parseContext(string(body))
+	if err != nil {
+		return providers.Metadata{}, fmt.Errorf("failed to parse context: %v", err)
+	}
+
+	return parseMetadata(vars)
+}
+
+func parseMetadata(vars map[string]string) (providers.Metadata, error) {
+	hostname := vars["SET_HOSTNAME"]
+	if hostname == "" {
+		hostname = vars["HOSTNAME"]
+	}
+
+	var keys []string
+	for _, key := range strings.Split(vars["SSH_PUBLIC_KEY"], "\n") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+
+	attrs := map[string]string{
+		"OPENNEBULA_HOSTNAME": hostname,
+	}
+
+	var network []providers.NetworkInterface
+	for _, n := range interfaceIndexes(vars) {
+		iface, err := parseInterface(vars, n)
+		if err != nil {
+			return providers.Metadata{}, err
+		}
+		network = append(network, iface)
+
+		attrs[fmt.Sprintf("OPENNEBULA_IPV4_%d", n)] = providers.String(net.ParseIP(vars[fmt.Sprintf("ETH%d_IP", n)]))
+		attrs[fmt.Sprintf("OPENNEBULA_IPV6_%d", n)] = providers.String(net.ParseIP(vars[fmt.Sprintf("ETH%d_IP6", n)]))
+	}
+
+	return providers.Metadata{
+		Attributes: attrs,
+		Hostname:   hostname,
+		Network:    network,
+		SshKeys:    keys,
+		Common:     providers.Common{InstanceID: vars["VMID"]},
+	}, nil
+}
+
+func interfaceIndexes(vars map[string]string) []int {
+	var indexes []int
+	for key := range vars {
+		if match := ifaceMAC.FindStringSubmatch(key); match != nil {
+			n, _ := strconv.Atoi(match[1])
+			indexes = append(indexes, n)
+		}
+	}
+	sort.Ints(indexes)
+	return indexes
+}
+
+func parseInterface(vars map[string]string, n int) (providers.NetworkInterface, error) {
+	get := func(suffix string) string {
+		return vars[fmt.Sprintf("ETH%d_%s", n, suffix)]
+	}
+
+	mac, err := net.ParseMAC(get("MAC"))
+	if err != nil {
+		return providers.NetworkInterface{}, fmt.Errorf("could not parse %q as MAC address", get("MAC"))
+	}
+	iface := providers.NetworkInterface{
+		HardwareAddress: mac,
+	}
+
+	for _, server := range strings.Fields(get("DNS")) {
+		addr := net.ParseIP(server)
+		if addr == nil {
+			return providers.NetworkInterface{}, fmt.Errorf("could not parse %q as IP address", server)
+		}
+		iface.Nameservers = append(iface.Nameservers, addr)
+	}
+
+	if ip := get("IP"); ip != "" {
+		addr := net.ParseIP(ip)
+		if addr == nil {
+			return providers.NetworkInterface{}, fmt.Errorf("could not parse %q as IPv4 address", ip)
+		}
+
+		mask := get("MASK")
+		if mask == "" {
+			mask = defaultMask
+		}
+		maskIP := net.ParseIP(mask)
+		if maskIP == nil || maskIP.To4() == nil {
+			return providers.NetworkInterface{}, fmt.Errorf("could not parse %q as IPv4 mask", mask)
+		}
+
+		iface.IPAddresses = append(iface.IPAddresses, net.IPNet{
+			IP:   addr,
+			Mask: net.IPMask(maskIP.To4()),
+		})
+	}
+
+	if ip := get("IP6"); ip != "" {
+		addr := net.ParseIP(ip)
+		if addr == nil {
+			return providers.NetworkInterface{}, fmt.Errorf("could not parse %q as IPv6 address", ip)
+		}
+
+		prefix := defaultPrefix
+		if p := get("IP6_PREFIX_LENGTH"); p != "" {
+			if prefix, err = strconv.Atoi(p); err != nil || prefix < 0 || prefix > net.IPv6len*8 {
+				return providers.NetworkInterface{}, fmt.Errorf("could not parse %q as IPv6 prefix length", p)
+			}
+		}
+
+		iface.IPAddresses = append(iface.IPAddresses, net.IPNet{
+			IP:   addr,
+			Mask: net.CIDRMask(prefix, net.IPv6len*8),
+		})
+	}
+
+	for _, gw := range []struct {
+		suffix string
+		zero   net.IP
+	}{
+		{"GATEWAY", net.IPv4zero},
+		{"GATEWAY6", net.IPv6zero},
+	} {
+		gateway := get(gw.suffix)
+		if gateway == "" {
+			continue
+		}
+
+		addr := net.ParseIP(gateway)
+		if addr == nil {
+			return providers.NetworkInterface{}, fmt.Errorf("could not parse %q as gateway address", gateway)
+		}
+
+		iface.Routes = append(iface.Routes, providers.NetworkRoute{
+			Destination: net.IPNet{
+				IP:   gw.zero,
+				Mask: net.IPMask(gw.zero),
+			},
+			Gateway: addr,
+		})
+	}
+
+	return iface, nil
+}
+
+// parseContext reads shell variable assignments without evaluating them.
+// Single-quoted, double-quoted and unquoted words are supported, but
+// expansions and command substitutions are kept literally.
+func parseContext(script string) (map[string]string, error) {
+	vars := map[string]string{}
+	s := []rune(script)
+	i := 0
+
+	skipComment := func() {
+		for i < len(s) && s[i] != '\n' {
+			i++
+		}
+	}
+
+	for i < len(s) {
+		switch c := s[i]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == ';':
+			i++
+			continue
+		case c == '#':
+			skipComment()
+			continue
+		}
+
+		start := i
+		for i < len(s) && isNameRune(s[i]) {
+			i++
+		}
+		name := string(s[start:i])
+		if name == "export" && i < len(s) && (s[i] == ' ' || s[i] == '\t') {
+			continue
+		}
+		if name == "" || i >= len(s) || s[i] != '=' {
+			return nil, fmt.Errorf("expected variable assignment at offset %d", start)
+		}
+		i++
+
+		var value []rune
+	word:
+		for i < len(s) {
+			switch s[i] {
+			case ' ', '\t', '\n', ';':
+				break word
+			case '\'':
+				end := i + 1
+				for end < len(s) && s[end] != '\'' {
+					end++
+				}
+				if end >= len(s) {
+					return nil, fmt.Errorf("unterminated single quote in %s", name)
+				}
+				value = append(value, s[i+1:end]...)
+				i = end + 1
+			case '"':
+				i++
+				for i < len(s) && s[i] != '"' {
+					if s[i] == '\\' && i+1 < len(s) && strings.ContainsRune("$`\"\\\n", s[i+1]) {
+						if s[i+1] != '\n' {
+							value = append(value, s[i+1])
+						}
+						i += 2
+						continue
+					}
+					value = append(value, s[i])
+					i++
+				}
+				if i >= len(s) {
+					return nil, fmt.Errorf("unterminated double quote in %s", name)
+				}
+				i++
+			case '\\':
+				if i+1 < len(s) && s[i+1] != '\n' {
+					value = append(value, s[i+1])
+				}
+				i += 2
+			default:
+				value = append(value, s[i])
+				i++
+			}
+		}
+
+		vars[name] = string(value)
+	}
+
+	return vars, nil
+}
+
+func isNameRune(r rune) bool {
+	return r == '_' || (r >= 'A' && r <= 'Z') || (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9')
+}
@@ -0,0 +1,157 @@
+package opennebula
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/coreos/coreos-metadata/internal/providers"
+)
+
+func TestParseContext(t *testing.T) {
+	tests := []struct {
+		desc   string
+		script string
+		vars   map[string]string
+		err    bool
+	}{
+		{
+			desc: "generated context",
+			script: `# Context variables generated by OpenNebula
+DISK_ID='1'
+ETH0_DNS='8.8.8.8 8.8.4.4'
+SSH_PUBLIC_KEY='ssh-rsa AAAA alice
+ssh-rsa BBBB bob'
+`,
+			vars: map[string]string{
+				"DISK_ID":        "1",
+				"ETH0_DNS":       "8.8.8.8 8.8.4.4",
+				"SSH_PUBLIC_KEY": "ssh-rsa AAAA alice\nssh-rsa BBBB bob",
+			},
+		},
+		{
+			desc:   "escaped single quote",
+			script: `NAME='it'\''s'` + "\n",
+			vars:   map[string]string{"NAME": "it's"},
+		},
+		{
+			desc:   "expansions are not evaluated",
+			script: `export A="$(reboot)" B=$HOME C=plain`,
+			vars:   map[string]string{"A": "$(reboot)", "B": "$HOME", "C": "plain"},
+		},
+		{
+			desc:   "command",
+			script: "reboot\n",
+			err:    true,
+		},
+		{
+			desc:   "unterminated quote",
+			script: "A='value\n",
+			err:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		vars, err := parseContext(tt.script)
+		if tt.err {
+			if err == nil {
+				t.Errorf("%s: expected an error", tt.desc)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.desc, err)
+			continue
+		}
+		if !reflect.DeepEqual(vars, tt.vars) {
+			t.Errorf("%s:\nwant: %q\n got: %q", tt.desc, tt.vars, vars)
+		}
+	}
+}
+
+func TestParseMetadata(t *testing.T) {
+	vars := map[string]string{
+		"SET_HOSTNAME":   "vm1",
+		"SSH_PUBLIC_KEY": "ssh-rsa AAAA alice\n",
+		"ETH0_MAC":       "02:00:0a:00:00:05",
+		"ETH0_IP":        "10.0.0.5",
+		"ETH0_MASK":      "255.255.255.0",
+		"ETH0_GATEWAY":   "10.0.0.1",
+		"ETH0_DNS":       "10.0.0.2",
+	}
+
+	mac, _ := net.ParseMAC("02:00:0a:00:00:05")
+	want := providers.Metadata{
+		Attributes: map[string]string{
+			"OPENNEBULA_HOSTNAME": "vm1",
+			"OPENNEBULA_IPV4_0":   "10.0.0.5",
+			"OPENNEBULA_IPV6_0":   "",
+		},
+		Hostname: "vm1",
+		SshKeys:  []string{"ssh-rsa AAAA alice"},
+		Network: []providers.NetworkInterface{
+			{
+				HardwareAddress: mac,
+				Nameservers:     []net.IP{net.ParseIP("10.0.0.2")},
+				IPAddresses: []net.IPNet{
+					{IP: net.ParseIP("10.0.0.5"), Mask: net.IPv4Mask(255, 255, 255, 0)},
+				},
+				Routes: []providers.NetworkRoute{
+					{
+						Destination: net.IPNet{IP: net.IPv4zero, Mask: net.IPMask(net.IPv4zero)},
+						Gateway:     net.ParseIP("10.0.0.1"),
+					},
+				},
+			},
+		},
+	}
+
+	got, err := parseMetadata(vars)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("\nwant: %#v\n got: %#v", want, got)
+	}
+	if err := Schema.Validate(got.Attributes); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestParseInterfacePrefixLength(t *testing.T) {
+	tests := []struct {
+		prefix string
+		mask   net.IPMask
+		err    bool
+	}{
+		{prefix: "", mask: net.CIDRMask(defaultPrefix, 128)},
+		{prefix: "48", mask: net.CIDRMask(48, 128)},
+		{prefix: "128", mask: net.CIDRMask(128, 128)},
+		{prefix: "129", err: true},
+		{prefix: "-1", err: true},
+		{prefix: "sixty-four", err: true},
+	}
+
+	for _, tt := range tests {
+		vars := map[string]string{
+			"ETH0_MAC":               "02:00:0a:00:00:05",
+			"ETH0_IP6":               "2001:db8::5",
+			"ETH0_IP6_PREFIX_LENGTH": tt.prefix,
+		}
+
+		iface, err := parseInterface(vars, 0)
+		if tt.err {
+			if err == nil {
+				t.Errorf("%q: expected an error, got %v", tt.prefix, iface.IPAddresses)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tt.prefix, err)
+			continue
+		}
+		if len(iface.IPAddresses) != 1 || !reflect.DeepEqual(iface.IPAddresses[0].Mask, tt.mask) {
+			t.Errorf("%q: want mask %v, got %v", tt.prefix, tt.mask, iface.IPAddresses)
+		}
+	}
+}
@@ -15,9 +15,7 @@
 package openstackMetadata
 
 import (
-	"fmt"
-	"path"
-	"strings"
+	"net"
 	"time"
 
 	"github.com/coreos/coreos-metadata/internal/providers"
@@ -28,6 +26,14 @@ const (
 	metadataEndpoint = "http://169.254.169.254/latest/meta-data/"
 )
 
+// Schema describes the attributes produced by FetchMetadata.
+var Schema = providers.Schema{
+	{Name: "OPENSTACK_HOSTNAME", Description: "The hostname of the instance", Type: providers.TypeString, Optional: true},
+	{Name: "OPENSTACK_INSTANCE_ID", Description: "The ID of the instance", Type: providers.TypeString, Optional: true},
+	{Name: "OPENSTACK_IPV4_LOCAL", Description: "The private IPv4 address", Type: providers.TypeIPv4, Optional: true},
+	{Name: "OPENSTACK_IPV4_PUBLIC", Description: "The public IPv4 address", Type: providers.TypeIPv4, Optional: true},
+}
+
 func FetchMetadata() (providers.Metadata, error) {
 	m := providers.Metadata{}
 	m.Attributes = make(map[string]string)
@@ -48,7 +54,14 @@ func FetchMetadata() (providers.Metadata, error) {
 		return providers.Metadata{}, err
 	}
 
-	keys, err := fetchKeys()
+	m.Common = providers.Common{
+		PrivateIPv4: net.ParseIP(m.Attributes["OPENSTACK_IPV4_LOCAL"]),
+		PublicIPv4:  net.ParseIP(m.Attributes["OPENSTACK_IPV4_PUBLIC"]),
+		InstanceID:  m.Attributes["OPENSTACK_INSTANCE_ID"],
+		Hostname:    m.Attributes["OPENSTACK_HOSTNAME"],
+	}
+
+	keys, err := providers.FetchEC2Keys(fetchMetadata, "")
 	if err != nil {
 		return providers.Metadata{}, err
 	}
@@ -69,38 +82,6 @@ func fetchAndSet(key, attrKey string, attributes map[string]string) error {
 	return nil
 }
 
-func fetchKeys() ([]string, error) {
-	keysListBlob, ok, err := fetchMetadata("public-keys")
-	if err != nil {
-		return nil, err
-	}
-	if !ok || keysListBlob == "" {
-		return nil, nil
-	}
-	keysList := strings.Split(keysListBlob, "\n")
-
-	var keys []string
-
-	if len(keysList) > 0 {
-		keyID := keysList[0]
-		keyTokens := strings.Split(keyID, "=")
-		if len(keyTokens) != 2 {
-			return nil, fmt.Errorf("error parsing keyID %s", keyID)
-		}
-		keyNum := keyTokens[0]
-		// keyTokens[1] is the name of the key, but is currently unused here
-		key, ok, err := fetchMetadata(path.Join("public-keys", keyNum, "openssh-key"))
-		if err != nil {
-			return nil, err
-		}
-		if !ok || key == "" {
-			return nil, fmt.Errorf("problem fetching key %s", keyID)
-		}
-		keys = append(keys, key)
-	}
-	return keys, nil
-}
-
 func fetchMetadata(key string) (string, bool, error) {
 	body, err := retry.Client{
 		InitialBackoff: time.Second,
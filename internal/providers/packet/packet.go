@@ -26,77 +26,177 @@ import (
 	"github.com/packethost/packngo/metadata"
 )
 
-func FetchMetadata() (providers.Metadata, error) {
-	body, err := retry.Client{
-		InitialBackoff: time.Second,
-		MaxBackoff:     time.Second * 5,
-		MaxAttempts:    10,
-	}.Get(metadata.BaseURL + "/metadata")
-	if err != nil {
-		return providers.Metadata{}, err
-	}
+var (
+	metadataEndpoint = metadata.BaseURL + "/metadata"
+)
 
-	var data struct {
-		Error        string `json:"error"`
-		PhoneHomeURL string `json:"phone_home_url"`
-		*metadata.CurrentDevice
-	}
+// Schema describes the attributes produced by FetchMetadata.
+var Schema = providers.Schema{
+	{Name: "PACKET_HOSTNAME", Description: "The hostname of the device", Type: providers.TypeString},
+	{Name: "PACKET_DEVICE_ID", Description: "The ID of the device", Type: providers.TypeString},
+	{Name: "PACKET_PHONE_HOME_URL", Description: "The URL notified by --phone-home", Type: providers.TypeURL, Optional: true},
+	{Name: "PACKET_FACILITY", Description: "The facility of the device", Type: providers.TypeString, Optional: true},
+	{Name: "PACKET_PLAN", Description: "The plan of the device", Type: providers.TypeString, Optional: true},
+	{Name: "PACKET_IQN", Description: "The iSCSI initiator name of the device", Type: providers.TypeString, Optional: true},
+	{Name: "PACKET_OS_SLUG", Description: "The slug of the operating system", Type: providers.TypeString, Optional: true},
+	{Name: "PACKET_OS_DISTRO", Description: "The distribution of the operating system", Type: providers.TypeString, Optional: true},
+	{Name: "PACKET_OS_VERSION", Description: "The version of the operating system", Type: providers.TypeString, Optional: true},
+	{Name: "PACKET_TAG", Description: "The nth tag of the device", Type: providers.TypeString, Indexed: true},
+	{Name: "PACKET_IPV4_PUBLIC", Description: "The nth public IPv4 address", Type: providers.TypeIPv4, Indexed: true},
+	{Name: "PACKET_IPV4_PRIVATE", Description: "The nth private IPv4 address", Type: providers.TypeIPv4, Indexed: true},
+	{Name: "PACKET_IPV6_PUBLIC", Description: "The nth public IPv6 address", Type: providers.TypeIPv6, Indexed: true},
+	{Name: "PACKET_IPV6_PRIVATE", Description: "The nth private IPv6 address", Type: providers.TypeIPv6, Indexed: true},
+	{Name: "PACKET_IPV4_PUBLIC_MANAGEMENT", Description: "The nth public IPv4 management address", Type: providers.TypeIPv4, Indexed: true},
+	{Name: "PACKET_IPV4_PRIVATE_MANAGEMENT", Description: "The nth private IPv4 management address", Type: providers.TypeIPv4, Indexed: true},
+	{Name: "PACKET_IPV6_PUBLIC_MANAGEMENT", Description: "The nth public IPv6 management address", Type: providers.TypeIPv6, Indexed: true},
+	{Name: "PACKET_IPV6_PRIVATE_MANAGEMENT", Description: "The nth private IPv6 management address", Type: providers.TypeIPv6, Indexed: true},
+}
 
-	if err := json.Unmarshal(body, &data); err != nil {
+func FetchMetadata() (providers.Metadata, error) {
+	data, err := fetchDeviceMetadata()
+	if err != nil {
 		return providers.Metadata{}, err
 	}
 
-	if data.Error != "" {
-		return providers.Metadata{}, errors.New(data.Error)
-	}
-
 	attrs := ipAddresses(data.Network)
 	attrs["PACKET_HOSTNAME"] = data.Hostname
 	attrs["PACKET_PHONE_HOME_URL"] = data.PhoneHomeURL
+	attrs["PACKET_DEVICE_ID"] = data.ID
+	attrs["PACKET_FACILITY"] = data.Facility
+	attrs["PACKET_PLAN"] = data.Plan
+	attrs["PACKET_IQN"] = data.IQN
+	attrs["PACKET_OS_SLUG"] = data.OS.Slug
+	attrs["PACKET_OS_DISTRO"] = data.OS.Distro
+	attrs["PACKET_OS_VERSION"] = data.OS.Version
+
+	for i, tag := range data.Tags {
+		attrs[fmt.Sprintf("PACKET_TAG_%d", i)] = tag
+	}
 
 	return providers.Metadata{
 		Attributes: attrs,
 		Hostname:   data.Hostname,
 		SshKeys:    data.SSHKeys,
+		Volumes:    volumes(data.IQN, data.Volumes),
+		Common: providers.Common{
+			PrivateIPv4: net.ParseIP(attrs["PACKET_IPV4_PRIVATE_0"]),
+			PublicIPv4:  net.ParseIP(attrs["PACKET_IPV4_PUBLIC_0"]),
+			Zone:        data.Facility,
+			InstanceID:  data.ID,
+		},
 	}, nil
 }
 
+// PhoneHome notifies Packet that the device has finished booting by posting
+// to the phone-home URL advertised in the metadata.
+func PhoneHome(m providers.Metadata) error {
+	url := m.Attributes["PACKET_PHONE_HOME_URL"]
+	if url == "" {
+		return errors.New("metadata does not contain a phone-home URL")
+	}
+
+	_, err := getClient().Post(url, nil)
+	return err
+}
+
+type deviceMetadata struct {
+	Error        string `json:"error"`
+	PhoneHomeURL string `json:"phone_home_url"`
+	*metadata.CurrentDevice
+}
+
+func getClient() retry.Client {
+	return retry.Client{
+		InitialBackoff: time.Second,
+		MaxBackoff:     time.Second * 5,
+		MaxAttempts:    10,
+	}
+}
+
+func fetchDeviceMetadata() (deviceMetadata, error) {
+	body, err := getClient().Get(metadataEndpoint)
+	if err != nil {
+		return deviceMetadata{}, err
+	}
+
+	data := deviceMetadata{CurrentDevice: &metadata.CurrentDevice{}}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return deviceMetadata{}, err
+	}
+
+	if data.Error != "" {
+		return deviceMetadata{}, errors.New(data.Error)
+	}
+
+	return data, nil
+}
+
 func ipAddresses(network metadata.NetworkInfo) map[string]string {
+	addresses := make(map[string]string)
+
 	var publicIPv4, privateIPv4, publicIPv6, privateIPv6 []net.IP
+	var mgmtPublicIPv4, mgmtPrivateIPv4, mgmtPublicIPv6, mgmtPrivateIPv6 []net.IP
 
 	for _, addr := range network.Addresses {
 		switch {
 		case addr.Family == 4 && addr.Public:
 			publicIPv4 = append(publicIPv4, addr.Address)
+			if addr.Management {
+				mgmtPublicIPv4 = append(mgmtPublicIPv4, addr.Address)
+			}
 
 		case addr.Family == 4 && !addr.Public:
 			privateIPv4 = append(privateIPv4, addr.Address)
+			if addr.Management {
+				mgmtPrivateIPv4 = append(mgmtPrivateIPv4, addr.Address)
+			}
 
 		case addr.Family == 6 && addr.Public:
 			publicIPv6 = append(publicIPv6, addr.Address)
+			if addr.Management {
+				mgmtPublicIPv6 = append(mgmtPublicIPv6, addr.Address)
+			}
 
 		case addr.Family == 6 && !addr.Public:
 			privateIPv6 = append(privateIPv6, addr.Address)
+			if addr.Management {
+				mgmtPrivateIPv6 = append(mgmtPrivateIPv6, addr.Address)
+			}
 		}
 	}
 
-	addresses := make(map[string]string)
+	setIndexed(addresses, "PACKET_IPV4_PUBLIC_%d", publicIPv4)
+	setIndexed(addresses, "PACKET_IPV4_PRIVATE_%d", privateIPv4)
+	setIndexed(addresses, "PACKET_IPV6_PUBLIC_%d", publicIPv6)
+	setIndexed(addresses, "PACKET_IPV6_PRIVATE_%d", privateIPv6)
 
-	for i, ip := range publicIPv4 {
-		addresses[fmt.Sprintf("PACKET_IPV4_PUBLIC_%d", i)] = providers.String(ip)
-	}
+	setIndexed(addresses, "PACKET_IPV4_PUBLIC_MANAGEMENT_%d", mgmtPublicIPv4)
+	setIndexed(addresses, "PACKET_IPV4_PRIVATE_MANAGEMENT_%d", mgmtPrivateIPv4)
+	setIndexed(addresses, "PACKET_IPV6_PUBLIC_MANAGEMENT_%d", mgmtPublicIPv6)
+	setIndexed(addresses, "PACKET_IPV6_PRIVATE_MANAGEMENT_%d", mgmtPrivateIPv6)
 
-	for i, ip := range privateIPv4 {
-		addresses[fmt.Sprintf("PACKET_IPV4_PRIVATE_%d", i)] = providers.String(ip)
-	}
+	return addresses
+}
 
-	for i, ip := range publicIPv6 {
-		addresses[fmt.Sprintf("PACKET_IPV6_PUBLIC_%d", i)] = providers.String(ip)
+func setIndexed(attrs map[string]string, format string, ips []net.IP) {
+	for i, ip := range ips {
+		attrs[fmt.Sprintf(format, i)] = providers.String(ip)
 	}
+}
 
-	for i, ip := range privateIPv6 {
-		addresses[fmt.Sprintf("PACKET_IPV6_PRIVATE_%d", i)] = providers.String(ip)
+func volumes(initiator string, infos []metadata.VolumeInfo) []providers.Volume {
+	var vols []providers.Volume
+	for _, info := range infos {
+		vol := providers.Volume{
+			Name:      info.Name,
+			Initiator: initiator,
+			Target:    info.IQN,
+			Portals:   info.IPs,
+		}
+		if info.Capacity.Size > 0 {
+			vol.Capacity = fmt.Sprintf("%d%s", info.Capacity.Size, info.Capacity.Unit)
+		}
+		vols = append(vols, vol)
 	}
-
-	return addresses
+	return vols
 }
@@ -0,0 +1,96 @@
+package packet
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/coreos/coreos-metadata/internal/providers"
+)
+
+func TestFetchMetadata(t *testing.T) {
+	server := httptest.NewServer(http.FileServer(http.Dir("testdata")))
+	defer server.Close()
+
+	tests := []struct {
+		desc     string
+		endpoint string
+		metadata providers.Metadata
+		err      bool
+	}{
+		{
+			desc:     "management, public and private addresses",
+			endpoint: server.URL + "/metadata.json",
+			metadata: providers.Metadata{
+				Attributes: map[string]string{
+					"PACKET_HOSTNAME":                  "worker-1",
+					"PACKET_DEVICE_ID":                 "6dc3c7a1-b3b4-4f86-a2a7-1a7f1c1e4a1d",
+					"PACKET_PHONE_HOME_URL":            "http://tinkerbell.ewr1.packet.net/phone-home",
+					"PACKET_FACILITY":                  "ewr1",
+					"PACKET_PLAN":                      "baremetal_0",
+					"PACKET_IQN":                       "iqn.2017-01.net.packet:device.6dc3c7a1",
+					"PACKET_OS_SLUG":                   "coreos_stable",
+					"PACKET_OS_DISTRO":                 "coreos",
+					"PACKET_OS_VERSION":                "stable",
+					"PACKET_TAG_0":                     "kubernetes",
+					"PACKET_TAG_1":                     "worker",
+					"PACKET_IPV4_PUBLIC_0":             "147.75.195.231",
+					"PACKET_IPV4_PUBLIC_1":             "147.75.84.10",
+					"PACKET_IPV4_PRIVATE_0":            "10.99.182.129",
+					"PACKET_IPV6_PUBLIC_0":             "2604:1380:1:5d00::1",
+					"PACKET_IPV4_PUBLIC_MANAGEMENT_0":  "147.75.195.231",
+					"PACKET_IPV4_PRIVATE_MANAGEMENT_0": "10.99.182.129",
+					"PACKET_IPV6_PUBLIC_MANAGEMENT_0":  "2604:1380:1:5d00::1",
+				},
+				Hostname: "worker-1",
+				SshKeys: []string{
+					"ssh-rsa AAAAB3NzaC1yc2EAAAADAQABAAABAQC0g+ZTxC7weoIJLUafOgrm+h core@example",
+				},
+				Volumes: []providers.Volume{
+					{
+						Name:      "volume-3ae5c5a2",
+						Initiator: "iqn.2017-01.net.packet:device.6dc3c7a1",
+						Target:    "iqn.2013-05.com.daterainc:tc:01:sn:b3f2e6a9c3f1e1a3",
+						Portals:   []net.IP{net.ParseIP("10.144.32.3"), net.ParseIP("10.144.48.3")},
+						Capacity:  "100gb",
+					},
+				},
+				Common: providers.Common{
+					PrivateIPv4: net.ParseIP("10.99.182.129"),
+					PublicIPv4:  net.ParseIP("147.75.195.231"),
+					Zone:        "ewr1",
+					InstanceID:  "6dc3c7a1-b3b4-4f86-a2a7-1a7f1c1e4a1d",
+				},
+			},
+		},
+		{
+			desc:     "error reported",
+			endpoint: server.URL + "/error.json",
+			err:      true,
+		},
+	}
+
+	defer func(endpoint string) { metadataEndpoint = endpoint }(metadataEndpoint)
+	for _, tt := range tests {
+		metadataEndpoint = tt.endpoint
+		metadata, err := FetchMetadata()
+		if tt.err {
+			if err == nil {
+				t.Errorf("%s: expected an error", tt.desc)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.desc, err)
+			continue
+		}
+		if !reflect.DeepEqual(metadata, tt.metadata) {
+			t.Errorf("%s:\nwant: %#v\n got: %#v", tt.desc, tt.metadata, metadata)
+		}
+		if err := Schema.Validate(metadata.Attributes); err != nil {
+			t.Errorf("%s: %v", tt.desc, err)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package qemu reads metadata passed to the VM through QEMU's fw_cfg
+// device, e.g. with:
+//
+//	-fw_cfg name=opt/org.coreos/metadata.json,file=metadata.json
+package qemu
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/coreos/coreos-metadata/internal/providers"
+)
+
+const (
+	metadataName = "opt/org.coreos/metadata.json"
+)
+
+var (
+	sysfsRoot = "/sys/firmware/qemu_fw_cfg"
+)
+
+type Route struct {
+	Destination string `json:"destination"`
+	Gateway     string `json:"gateway"`
+}
+
+type Interface struct {
+	Name        string   `json:"name"`
+	MAC         string   `json:"mac"`
+	Nameservers []string `json:"nameservers"`
+	Addresses   []string `json:"addresses"`
+	Routes      []Route  `json:"routes"`
+}
+
+type Metadata struct {
+	Hostname   string            `json:"hostname"`
+	SshKeys    []string          `json:"ssh_keys"`
+	Attributes map[string]string `json:"attributes"`
+	Network    []Interface       `json:"network"`
+}
+
+// Schema describes the attributes produced by FetchMetadata.
+var Schema = providers.Schema{
+	{Name: "QEMU_HOSTNAME", Description: "The hostname from the metadata", Type: providers.TypeString, Optional: true},
+	{Name: "QEMU", Description: "The value of each entry in `attributes`, named after its key in upper case with other characters than letters, digits and underscores replaced by underscores", Type: providers.TypeString, Wildcard: true},
+}
+
+func FetchMetadata() (providers.Metadata, error) {
+	body, err := ioutil.ReadFile(filepath.Join(sysfsRoot, "by_name", metadataName, "raw"))
+	if os.IsNotExist(err) {
+		return providers.Metadata{}, fmt.Errorf("fw_cfg entry %q not found (is the qemu_fw_cfg module loaded?)", metadataName)
+	} else if err != nil {
+		return providers.Metadata{}, fmt.Errorf("failed to read fw_cfg entry %q: %v", metadataName, err)
+	}
+
+	var m Metadata
+	if err := json.Unmarshal(body, &m); err != nil {
+		return providers.Metadata{}, fmt.Errorf("failed to unmarshal metadata: %v", err)
+	}
+
+	network, err := parseNetwork(m.Network)
+	if err != nil {
+		return providers.Metadata{}, fmt.Errorf("failed to parse network config from metadata: %v", err)
+	}
+
+	attrs, err := parseAttributes(m)
+	if err != nil {
+		return providers.Metadata{}, fmt.Errorf("failed to parse attributes from metadata: %v", err)
+	}
+
+	return providers.Metadata{
+		Attributes: attrs,
+		Hostname:   m.Hostname,
+		Network:    network,
+		SshKeys:    m.SshKeys,
+	}, nil
+}
+
+// parseAttributes names the user's attributes after their keys, which are
+// upper-cased and have any characters which are not valid in environment
+// variable names replaced with underscores. Keys which would overwrite
+// another attribute are rejected.
+func parseAttributes(m Metadata) (map[string]string, error) {
+	attrs := map[string]string{
+		"QEMU_HOSTNAME": m.Hostname,
+	}
+
+	var keys []string
+	for key := range m.Attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if key == "" {
+			return nil, fmt.Errorf("attribute with an empty key")
+		}
+		name := "QEMU_" + strings.Map(func(r rune) rune {
+			switch {
+			case r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_':
+				return r
+			case r >= 'a' && r <= 'z':
+				return r - 'a' + 'A'
+			default:
+				return '_'
+			}
+		}, key)
+
+		if _, ok := attrs[name]; ok {
+			return nil, fmt.Errorf("attribute %q would overwrite %s", key, name)
+		}
+		attrs[name] = m.Attributes[key]
+	}
+
+	return attrs, nil
+}
+
+func parseNetwork(ifaces []Interface) ([]providers.NetworkInterface, error) {
+	var configs []providers.NetworkInterface
+	for _, iface := range ifaces {
+		config := providers.NetworkInterface{
+			Name: iface.Name,
+		}
+
+		if iface.MAC != "" {
+			mac, err := net.ParseMAC(iface.MAC)
+			if err != nil {
+				return nil, fmt.Errorf("could not parse %q as MAC address", iface.MAC)
+			}
+			config.HardwareAddress = mac
+		} else if iface.Name == "" {
+			return nil, fmt.Errorf("interface has neither a name nor a MAC address")
+		}
+
+		for _, server := range iface.Nameservers {
+			addr := net.ParseIP(server)
+			if addr == nil {
+				return nil, fmt.Errorf("could not parse %q as IP address", server)
+			}
+			config.Nameservers = append(config.Nameservers, addr)
+		}
+
+		for _, address := range iface.Addresses {
+			ip, network, err := net.ParseCIDR(address)
+			if err != nil {
+				return nil, fmt.Errorf("could not parse %q as IP address", address)
+			}
+			config.IPAddresses = append(config.IPAddresses, net.IPNet{
+				IP:   ip,
+				Mask: network.Mask,
+			})
+		}
+
+		for _, route := range iface.Routes {
+			_, destination, err := net.ParseCIDR(route.Destination)
+			if err != nil {
+				return nil, fmt.Errorf("could not parse %q as route destination", route.Destination)
+			}
+
+			gateway := net.ParseIP(route.Gateway)
+			if gateway == nil {
+				return nil, fmt.Errorf("could not parse %q as gateway address", route.Gateway)
+			}
+
+			config.Routes = append(config.Routes, providers.NetworkRoute{
+				Destination: *destination,
+				Gateway:     gateway,
+			})
+		}
+
+		configs = append(configs, config)
+	}
+
+	return configs, nil
+}
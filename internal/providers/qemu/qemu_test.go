@@ -0,0 +1,127 @@
+package qemu
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/coreos/coreos-metadata/internal/providers"
+)
+
+func TestFetchMetadata(t *testing.T) {
+	mac, _ := net.ParseMAC("52:54:00:12:34:56")
+
+	tests := []struct {
+		desc     string
+		root     string
+		metadata providers.Metadata
+		err      bool
+	}{
+		{
+			desc: "metadata present",
+			root: "testdata/sysfs",
+			metadata: providers.Metadata{
+				Attributes: map[string]string{
+					"QEMU_HOSTNAME": "ci-worker-1",
+					"QEMU_ROLE":     "worker",
+				},
+				Hostname: "ci-worker-1",
+				SshKeys:  []string{"ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIGxi core@ci"},
+				Network: []providers.NetworkInterface{
+					{
+						HardwareAddress: mac,
+						Nameservers:     []net.IP{net.ParseIP("10.0.2.3")},
+						IPAddresses: []net.IPNet{
+							{IP: net.ParseIP("10.0.2.15"), Mask: net.CIDRMask(24, 32)},
+						},
+						Routes: []providers.NetworkRoute{
+							{
+								Destination: net.IPNet{IP: net.IPv4zero.To4(), Mask: net.CIDRMask(0, 32)},
+								Gateway:     net.ParseIP("10.0.2.2"),
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			desc: "fw_cfg missing",
+			root: "testdata/missing",
+			err:  true,
+		},
+	}
+
+	defer func(root string) { sysfsRoot = root }(sysfsRoot)
+	for _, tt := range tests {
+		sysfsRoot = tt.root
+		metadata, err := FetchMetadata()
+		if tt.err {
+			if err == nil {
+				t.Errorf("%s: expected an error", tt.desc)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.desc, err)
+			continue
+		}
+		if !reflect.DeepEqual(metadata, tt.metadata) {
+			t.Errorf("%s:\nwant: %#v\n got: %#v", tt.desc, tt.metadata, metadata)
+		}
+		if err := Schema.Validate(metadata.Attributes); err != nil {
+			t.Errorf("%s: %v", tt.desc, err)
+		}
+	}
+}
+
+func TestParseAttributes(t *testing.T) {
+	tests := []struct {
+		desc  string
+		attrs map[string]string
+		want  map[string]string
+		err   bool
+	}{
+		{
+			desc:  "invalid characters",
+			attrs: map[string]string{"etcd.peer-url": "http://10.0.2.15:2380", "my role": "worker", "über": "x"},
+			want: map[string]string{
+				"QEMU_HOSTNAME":      "node1",
+				"QEMU_ETCD_PEER_URL": "http://10.0.2.15:2380",
+				"QEMU_MY_ROLE":       "worker",
+				"QEMU__BER":          "x",
+			},
+		},
+		{
+			desc:  "reserved key",
+			attrs: map[string]string{"hostname": "other"},
+			err:   true,
+		},
+		{
+			desc:  "colliding keys",
+			attrs: map[string]string{"peer-url": "a", "peer_url": "b"},
+			err:   true,
+		},
+		{
+			desc:  "empty key",
+			attrs: map[string]string{"": "a"},
+			err:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := parseAttributes(Metadata{Hostname: "node1", Attributes: tt.attrs})
+		if tt.err {
+			if err == nil {
+				t.Errorf("%s: expected an error, got %v", tt.desc, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.desc, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s:\nwant: %v\n got: %v", tt.desc, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scaleway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/coreos/coreos-metadata/internal/providers"
+	"github.com/coreos/coreos-metadata/internal/retry"
+)
+
+var (
+	metadataEndpoint = "http://169.254.42.42/"
+
+	// transport connects from a privileged port, which the metadata service
+	// requires.
+	transport http.RoundTripper = &http.Transport{
+		DialContext: dialPrivileged,
+	}
+)
+
+type Address struct {
+	Address string `json:"address"`
+	Gateway string `json:"gateway"`
+	Netmask string `json:"netmask"`
+}
+
+type SshKey struct {
+	Key         string `json:"key"`
+	Fingerprint string `json:"fingerprint"`
+}
+
+type Metadata struct {
+	ID             string   `json:"id"`
+	Hostname       string   `json:"hostname"`
+	CommercialType string   `json:"commercial_type"`
+	PrivateIP      string   `json:"private_ip"`
+	PublicIP       *Address `json:"public_ip"`
+	IPv6           *Address `json:"ipv6"`
+	Location       struct {
+		ZoneID string `json:"zone_id"`
+	} `json:"location"`
+	SshPublicKeys []SshKey `json:"ssh_public_keys"`
+}
+
+// Schema describes the attributes produced by FetchMetadata.
+var Schema = providers.Schema{
+	{Name: "SCALEWAY_INSTANCE_ID", Description: "The ID of the server", Type: providers.TypeString},
+	{Name: "SCALEWAY_HOSTNAME", Description: "The hostname of the server", Type: providers.TypeString},
+	{Name: "SCALEWAY_COMMERCIAL_TYPE", Description: "The commercial type of the server", Type: providers.TypeString, Optional: true},
+	{Name: "SCALEWAY_ZONE", Description: "The zone of the server", Type: providers.TypeString, Optional: true},
+	{Name: "SCALEWAY_IPV4_PRIVATE", Description: "The private IPv4 address", Type: providers.TypeIPv4, Optional: true},
+	{Name: "SCALEWAY_IPV4_PUBLIC", Description: "The public IPv4 address", Type: providers.TypeIPv4, Optional: true},
+	{Name: "SCALEWAY_IPV6_PUBLIC", Description: "The public IPv6 address", Type: providers.TypeIPv6, Optional: true},
+}
+
+func FetchMetadata() (providers.Metadata, error) {
+	body, err := getClient().Get(metadataEndpoint + "conf?format=json")
+	if err != nil {
+		return providers.Metadata{}, fmt.Errorf("failed to fetch metadata: %v", err)
+	}
+
+	var m Metadata
+	if err := json.Unmarshal(body, &m); err != nil {
+		return providers.Metadata{}, fmt.Errorf("failed to unmarshal metadata: %v", err)
+	}
+
+	return providers.Metadata{
+		Attributes: parseAttributes(m),
+		Hostname:   m.Hostname,
+		SshKeys:    parseKeys(m),
+		Common:     parseCommon(m),
+	}, nil
+}
+
+func parseKeys(metadata Metadata) []string {
+	var keys []string
+	for _, key := range metadata.SshPublicKeys {
+		keys = append(keys, key.Key)
+	}
+	return keys
+}
+
+func parseCommon(metadata Metadata) providers.Common {
+	common := providers.Common{
+		PrivateIPv4: net.ParseIP(metadata.PrivateIP),
+		Zone:        metadata.Location.ZoneID,
+		InstanceID:  metadata.ID,
+	}
+	if metadata.PublicIP != nil {
+		common.PublicIPv4 = net.ParseIP(metadata.PublicIP.Address)
+	}
+
+	return common
+}
+
+// ReportBooted sets the server's state to "booted" in the Scaleway API.
+func ReportBooted(providers.Metadata) error {
+	client := getClient()
+	client.Header = map[string][]string{
+		"Content-Type": {"application/json"},
+	}
+
+	_, err := client.Patch(metadataEndpoint+"state", []byte(`{"state_detail":"booted"}`))
+	return err
+}
+
+func parseAttributes(metadata Metadata) map[string]string {
+	attrs := map[string]string{
+		"SCALEWAY_INSTANCE_ID":     metadata.ID,
+		"SCALEWAY_HOSTNAME":        metadata.Hostname,
+		"SCALEWAY_COMMERCIAL_TYPE": metadata.CommercialType,
+		"SCALEWAY_ZONE":            metadata.Location.ZoneID,
+		"SCALEWAY_IPV4_PRIVATE":    providers.String(net.ParseIP(metadata.PrivateIP)),
+	}
+
+	if metadata.PublicIP != nil {
+		attrs["SCALEWAY_IPV4_PUBLIC"] = providers.String(net.ParseIP(metadata.PublicIP.Address))
+	}
+	if metadata.IPv6 != nil {
+		attrs["SCALEWAY_IPV6_PUBLIC"] = providers.String(net.ParseIP(metadata.IPv6.Address))
+	}
+
+	return attrs
+}
+
+func getClient() retry.Client {
+	return retry.Client{
+		InitialBackoff: time.Second,
+		MaxBackoff:     time.Second * 5,
+		MaxAttempts:    10,
+		Transport:      transport,
+	}
+}
+
+// dialPrivileged connects from a privileged source port, which the Scaleway
+// metadata service requires. Ports are tried in turn until one is free.
+func dialPrivileged(ctx context.Context, network, addr string) (net.Conn, error) {
+	var err error
+	for port := 1023; port > 0; port-- {
+		dialer := net.Dialer{
+			LocalAddr: &net.TCPAddr{Port: port},
+			Timeout:   10 * time.Second,
+		}
+
+		var conn net.Conn
+		conn, err = dialer.DialContext(ctx, network, addr)
+		if err == nil {
+			return conn, nil
+		}
+
+		if opErr, ok := err.(*net.OpError); !ok || !isAddrInUse(opErr.Err) {
+			return nil, err
+		}
+	}
+
+	return nil, fmt.Errorf("no privileged port available: %v", err)
+}
+
+func isAddrInUse(err error) bool {
+	if sysErr, ok := err.(*os.SyscallError); ok {
+		err = sysErr.Err
+	}
+	return err == syscall.EADDRINUSE
+}
@@ -0,0 +1,104 @@
+package scaleway
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/coreos/coreos-metadata/internal/providers"
+)
+
+func TestFetchMetadata(t *testing.T) {
+	conf, err := ioutil.ReadFile("testdata/conf.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var state string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/conf" && r.URL.Query().Get("format") == "json":
+			w.Write(conf)
+		case r.Method == "PATCH" && r.URL.Path == "/state":
+			if r.Header.Get("Content-Type") != "application/json" {
+				http.Error(w, "", http.StatusUnsupportedMediaType)
+				return
+			}
+			body, _ := ioutil.ReadAll(r.Body)
+			state = string(body)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	defer func(endpoint string, rt http.RoundTripper) {
+		metadataEndpoint = endpoint
+		transport = rt
+	}(metadataEndpoint, transport)
+	metadataEndpoint = server.URL + "/"
+	transport = nil
+
+	want := providers.Metadata{
+		Attributes: map[string]string{
+			"SCALEWAY_INSTANCE_ID":     "8a1bb6c7-5f3c-4c8a-9a5f-2c8d6e1b9f10",
+			"SCALEWAY_HOSTNAME":        "node1",
+			"SCALEWAY_COMMERCIAL_TYPE": "DEV1-S",
+			"SCALEWAY_ZONE":            "fr-par-1",
+			"SCALEWAY_IPV4_PRIVATE":    "10.68.34.21",
+			"SCALEWAY_IPV4_PUBLIC":     "51.15.220.10",
+			"SCALEWAY_IPV6_PUBLIC":     "2001:bc8:4700:2500::1d13",
+		},
+		Hostname: "node1",
+		SshKeys:  []string{"ssh-rsa AAAA core@example", "ssh-ed25519 AAAAC3 core@laptop"},
+		Common: providers.Common{
+			PrivateIPv4: net.ParseIP("10.68.34.21"),
+			PublicIPv4:  net.ParseIP("51.15.220.10"),
+			Zone:        "fr-par-1",
+			InstanceID:  "8a1bb6c7-5f3c-4c8a-9a5f-2c8d6e1b9f10",
+		},
+	}
+
+	got, err := FetchMetadata()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("bad metadata:\nwant: %#v\n got: %#v", want, got)
+	}
+	if err := Schema.Validate(got.Attributes); err != nil {
+		t.Error(err)
+	}
+
+	if err := ReportBooted(got); err != nil {
+		t.Fatalf("unexpected error reporting state: %v", err)
+	}
+	if want := `{"state_detail":"booted"}`; state != want {
+		t.Errorf("bad state: want %q, got %q", want, state)
+	}
+}
+
+func TestParseAttributes(t *testing.T) {
+	// servers without a public IP or IPv6 leave those attributes unset
+	m := Metadata{ID: "8a1bb6c7", Hostname: "node1", PrivateIP: "10.68.34.21"}
+	want := map[string]string{
+		"SCALEWAY_INSTANCE_ID":     "8a1bb6c7",
+		"SCALEWAY_HOSTNAME":        "node1",
+		"SCALEWAY_COMMERCIAL_TYPE": "",
+		"SCALEWAY_ZONE":            "",
+		"SCALEWAY_IPV4_PRIVATE":    "10.68.34.21",
+	}
+	if got := parseAttributes(m); !reflect.DeepEqual(got, want) {
+		t.Errorf("\nwant: %v\n got: %v", want, got)
+	}
+	if common := parseCommon(m); common.PublicIPv4 != nil {
+		t.Errorf("unexpected public address: %v", common.PublicIPv4)
+	}
+	if keys := parseKeys(m); keys != nil {
+		t.Errorf("unexpected keys: %v", keys)
+	}
+}
@@ -0,0 +1,164 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package providers
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// AttributeType is the kind of value held by an attribute.
+type AttributeType string
+
+const (
+	TypeString  AttributeType = "string"
+	TypeInteger AttributeType = "integer"
+	TypeIPv4    AttributeType = "ipv4"
+	TypeIPv6    AttributeType = "ipv6"
+	TypeCIDR    AttributeType = "cidr"
+	TypeURL     AttributeType = "url"
+)
+
+// Attribute describes an attribute which a provider can produce.
+type Attribute struct {
+	// Name is the key in Metadata.Attributes, which is written with the
+	// COREOS_ prefix.
+	Name        string
+	Description string
+	Type        AttributeType
+	// Optional attributes may be empty or missing.
+	Optional bool
+	// Indexed attributes are numbered from 0, as NAME_0, NAME_1, ...
+	Indexed bool
+	// Wildcard attributes stand for any attribute starting with NAME_,
+	// for attributes named by the user.
+	Wildcard bool
+}
+
+// Key returns the name under which the attribute is written, with a
+// placeholder for the index or user-chosen part of the name.
+func (a Attribute) Key() string {
+	switch {
+	case a.Indexed:
+		return fmt.Sprintf("COREOS_%s_<n>", a.Name)
+	case a.Wildcard:
+		return fmt.Sprintf("COREOS_%s_<name>", a.Name)
+	default:
+		return "COREOS_" + a.Name
+	}
+}
+
+// Schema lists the attributes which a provider can produce.
+type Schema []Attribute
+
+// Lookup returns the attribute describing key.
+func (s Schema) Lookup(key string) (Attribute, bool) {
+	for _, attr := range s {
+		if attr.Name == key && !attr.Indexed && !attr.Wildcard {
+			return attr, true
+		}
+	}
+	for _, attr := range s {
+		suffix := strings.TrimPrefix(key, attr.Name+"_")
+		if suffix == key || suffix == "" {
+			continue
+		}
+		if attr.Wildcard {
+			return attr, true
+		}
+		if _, err := strconv.ParseUint(suffix, 10, 32); attr.Indexed && err == nil {
+			return attr, true
+		}
+	}
+	return Attribute{}, false
+}
+
+// Validate checks that the attributes are described by the schema, that
+// their values are of the described type and that required attributes are
+// set.
+func (s Schema) Validate(attrs map[string]string) error {
+	var keys []string
+	for key := range attrs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		attr, ok := s.Lookup(key)
+		if !ok {
+			return fmt.Errorf("attribute %s is not described by the schema", key)
+		}
+		if value := attrs[key]; value != "" {
+			if err := attr.Type.check(value); err != nil {
+				return fmt.Errorf("attribute %s: %v", key, err)
+			}
+		}
+	}
+
+	for _, attr := range s {
+		if !attr.Optional && !attr.Indexed && !attr.Wildcard && attrs[attr.Name] == "" {
+			return fmt.Errorf("required attribute %s is not set", attr.Name)
+		}
+	}
+
+	return nil
+}
+
+// Markdown returns a table describing the attributes.
+func (s Schema) Markdown() string {
+	table := "| Attribute | Type | Description |\n|---|---|---|\n"
+	for _, attr := range s {
+		description := attr.Description
+		if attr.Optional {
+			description += " (optional)"
+		}
+		table += fmt.Sprintf("| `%s` | %s | %s |\n", attr.Key(), attr.Type, description)
+	}
+	return table
+}
+
+func (t AttributeType) check(value string) error {
+	var ok bool
+	switch t {
+	case TypeString:
+		ok = true
+	case TypeInteger:
+		_, err := strconv.ParseInt(value, 10, 64)
+		ok = err == nil
+	case TypeIPv4:
+		ip := net.ParseIP(value)
+		ok = ip != nil && ip.To4() != nil
+	case TypeIPv6:
+		ip := net.ParseIP(value)
+		ok = ip != nil && ip.To4() == nil
+	case TypeCIDR:
+		_, _, err := net.ParseCIDR(value)
+		ok = err == nil
+	case TypeURL:
+		u, err := url.Parse(value)
+		ok = err == nil && u.Scheme != "" && u.Host != ""
+	default:
+		return fmt.Errorf("unknown type %q", t)
+	}
+
+	if !ok {
+		return fmt.Errorf("%q is not a valid %s", value, t)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package providers
+
+import (
+	"testing"
+)
+
+func TestSchema(t *testing.T) {
+	schema := Schema{
+		{Name: "TEST_HOSTNAME", Type: TypeString},
+		{Name: "TEST_ZONE", Type: TypeString, Optional: true},
+		{Name: "TEST_INSTANCE_ID", Type: TypeInteger, Optional: true},
+		{Name: "TEST_IPV4", Type: TypeIPv4, Indexed: true},
+		{Name: "TEST_IPV4_ANCHOR", Type: TypeIPv4, Indexed: true},
+		{Name: "TEST_IPV6", Type: TypeIPv6, Indexed: true},
+		{Name: "TEST_RANGE", Type: TypeCIDR, Optional: true},
+		{Name: "TEST_URL", Type: TypeURL, Optional: true},
+		{Name: "TEST_USER", Type: TypeString, Wildcard: true},
+	}
+
+	tests := []struct {
+		desc  string
+		attrs map[string]string
+		err   bool
+	}{
+		{
+			desc: "valid",
+			attrs: map[string]string{
+				"TEST_HOSTNAME":      "node1",
+				"TEST_ZONE":          "",
+				"TEST_INSTANCE_ID":   "42",
+				"TEST_IPV4_0":        "10.0.0.1",
+				"TEST_IPV4_ANCHOR_0": "10.0.0.2",
+				"TEST_IPV6_1":        "2001:db8::1",
+				"TEST_RANGE":         "2001:db8::/64",
+				"TEST_URL":           "https://example.com/phone-home",
+				"TEST_USER_ROLE":     "worker",
+			},
+		},
+		{
+			desc:  "missing required",
+			attrs: map[string]string{"TEST_ZONE": "a"},
+			err:   true,
+		},
+		{
+			desc:  "unknown",
+			attrs: map[string]string{"TEST_HOSTNAME": "node1", "TEST_REGION": "a"},
+			err:   true,
+		},
+		{
+			desc:  "unindexed",
+			attrs: map[string]string{"TEST_HOSTNAME": "node1", "TEST_IPV4": "10.0.0.1"},
+			err:   true,
+		},
+		{
+			desc:  "bad integer",
+			attrs: map[string]string{"TEST_HOSTNAME": "node1", "TEST_INSTANCE_ID": "i-42"},
+			err:   true,
+		},
+		{
+			desc:  "ipv6 as ipv4",
+			attrs: map[string]string{"TEST_HOSTNAME": "node1", "TEST_IPV4_0": "2001:db8::1"},
+			err:   true,
+		},
+		{
+			desc:  "ipv4 as ipv6",
+			attrs: map[string]string{"TEST_HOSTNAME": "node1", "TEST_IPV6_0": "10.0.0.1"},
+			err:   true,
+		},
+		{
+			desc:  "bad cidr",
+			attrs: map[string]string{"TEST_HOSTNAME": "node1", "TEST_RANGE": "2001:db8::"},
+			err:   true,
+		},
+		{
+			desc:  "bad url",
+			attrs: map[string]string{"TEST_HOSTNAME": "node1", "TEST_URL": "example.com"},
+			err:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		err := schema.Validate(tt.attrs)
+		if tt.err && err == nil {
+			t.Errorf("%s: expected an error", tt.desc)
+		} else if !tt.err && err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.desc, err)
+		}
+	}
+
+	want := "| Attribute | Type | Description |\n|---|---|---|\n" +
+		"| `COREOS_TEST_ZONE` | string | The zone (optional) |\n" +
+		"| `COREOS_TEST_IPV4_<n>` | ipv4 | The nth address |\n" +
+		"| `COREOS_TEST_USER_<name>` | string | User attributes |\n"
+	got := Schema{
+		{Name: "TEST_ZONE", Description: "The zone", Type: TypeString, Optional: true},
+		{Name: "TEST_IPV4", Description: "The nth address", Type: TypeIPv4, Indexed: true},
+		{Name: "TEST_USER", Description: "User attributes", Type: TypeString, Wildcard: true},
+	}.Markdown()
+	if got != want {
+		t.Errorf("\nwant: %q\n got: %q", want, got)
+	}
+}
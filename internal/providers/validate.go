@@ -0,0 +1,202 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package providers
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+)
+
+// ValidateNetwork checks the interfaces returned by a provider for conflicts
+// and returns a normalised copy. Problems which have an unambiguous fix are
+// fixed:
+//
+//   - IPv4 addresses, destinations and gateways are stored in their 4-byte
+//     form and IPv4-mapped masks are shortened to match
+//   - the host bits of route destinations are cleared, e.g. a route to
+//     10.0.0.5/16 becomes one to 10.0.0.0/16, as the kernel requires
+//   - addresses repeated on the same interface are dropped
+//   - default routes of the same family which would tie are given increasing
+//     metrics, so that the first one reported remains preferred
+//   - gateways outside of the interface's subnets are marked as on-link
+//
+// Anything else, such as an address assigned to two interfaces, an IPv6
+// address with an IPv4 mask, a route whose gateway is of the other family or
+// a gateway which cannot be reached at all, is rejected.
+func ValidateNetwork(ifaces []NetworkInterface) ([]NetworkInterface, error) {
+	valid := make([]NetworkInterface, len(ifaces))
+	owners := map[string]string{}
+
+	for i, iface := range ifaces {
+		id := interfaceID(iface)
+
+		var addrs []net.IPNet
+		seen := map[string]bool{}
+		for _, addr := range iface.IPAddresses {
+			addr, err := normaliseIPNet(addr)
+			if err != nil {
+				return nil, fmt.Errorf("interface %s: address %s: %v", id, addr.String(), err)
+			}
+
+			key := addr.IP.String()
+			if owner, ok := owners[key]; ok && owner != id {
+				return nil, fmt.Errorf("interface %s: address %s is also assigned to %s", id, key, owner)
+			}
+			owners[key] = id
+			if seen[addr.String()] {
+				continue
+			}
+			seen[addr.String()] = true
+			addrs = append(addrs, addr)
+		}
+
+		var routes []NetworkRoute
+		for _, route := range iface.Routes {
+			dest, err := normaliseIPNet(route.Destination)
+			if err != nil {
+				return nil, fmt.Errorf("interface %s: route to %s: %v", id, route.Destination.String(), err)
+			}
+			dest.IP = dest.IP.Mask(dest.Mask)
+			route.Destination = dest
+
+			if route.Gateway != nil {
+				route.Gateway = normaliseIP(route.Gateway)
+				if len(route.Gateway) != len(dest.IP) {
+					return nil, fmt.Errorf("interface %s: route to %s: gateway %s is of a different address family", id, dest.String(), route.Gateway)
+				}
+
+				onLink, err := gatewayOnLink(iface, addrs, route.Gateway)
+				if err != nil {
+					return nil, fmt.Errorf("interface %s: route to %s: %v", id, dest.String(), err)
+				}
+				route.OnLink = route.OnLink || onLink
+			}
+			routes = append(routes, route)
+		}
+
+		iface.IPAddresses = addrs
+		iface.Routes = routes
+		valid[i] = iface
+	}
+
+	spreadDefaultRoutes(valid)
+
+	return valid, nil
+}
+
+// gatewayOnLink reports whether gw must be marked as on-link to be usable
+// from an interface with the given static addresses. An error is returned
+// if the gateway cannot be reached at all.
+func gatewayOnLink(iface NetworkInterface, addrs []net.IPNet, gw net.IP) (bool, error) {
+	if gw.IsLinkLocalUnicast() {
+		return false, nil
+	}
+
+	dynamic := iface.DHCP&DHCPv4 != 0
+	if gw.To4() == nil {
+		dynamic = iface.DHCP&(DHCPv6|SLAAC) != 0
+	}
+
+	local := false
+	for _, addr := range addrs {
+		if len(addr.IP) != len(gw) {
+			continue
+		}
+		if addr.Contains(gw) {
+			return false, nil
+		}
+		local = true
+	}
+
+	switch {
+	case dynamic:
+		// the subnet is not known until a lease is obtained
+		return false, nil
+	case local:
+		return true, nil
+	default:
+		return false, fmt.Errorf("gateway %s is unreachable without an address of its family", gw)
+	}
+}
+
+// spreadDefaultRoutes assigns metrics to default routes which would
+// otherwise tie with an earlier default route of the same family.
+func spreadDefaultRoutes(ifaces []NetworkInterface) {
+	used := map[int]map[int]bool{}
+	for i := range ifaces {
+		for j := range ifaces[i].Routes {
+			route := &ifaces[i].Routes[j]
+			if ones, _ := route.Destination.Mask.Size(); ones != 0 {
+				continue
+			}
+
+			family := len(route.Destination.IP)
+			if used[family] == nil {
+				used[family] = map[int]bool{}
+			}
+			for used[family][route.Metric] {
+				route.Metric++
+			}
+			used[family][route.Metric] = true
+		}
+	}
+}
+
+func normaliseIP(ip net.IP) net.IP {
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4
+	}
+	return ip
+}
+
+var (
+	v4InV6Prefix = []byte{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0xff, 0xff}
+	v6MaskPrefix = []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+)
+
+func normaliseIPNet(n net.IPNet) (net.IPNet, error) {
+	ip := normaliseIP(n.IP)
+	if ip == nil {
+		return n, fmt.Errorf("invalid address")
+	}
+
+	mask := n.Mask
+	if len(ip) == net.IPv4len && len(mask) == net.IPv6len {
+		// IPv4 masks are either written like IPv6 ones, e.g. /120 for /24,
+		// or built from a 16-byte IPv4 address, as with
+		// net.IPMask(net.IPv4zero) for default routes. Either way the mask
+		// is held by the last 4 bytes.
+		if !bytes.Equal(mask[:12], v4InV6Prefix) && !bytes.Equal(mask[:12], v6MaskPrefix) {
+			return n, fmt.Errorf("IPv4 address has an IPv6 mask")
+		}
+		mask = mask[12:]
+	}
+	if len(ip) != len(mask) {
+		return n, fmt.Errorf("IPv6 address has an IPv4 mask")
+	}
+	if _, bits := mask.Size(); bits == 0 {
+		return n, fmt.Errorf("non-contiguous mask %s", mask)
+	}
+
+	return net.IPNet{IP: ip, Mask: mask}, nil
+}
+
+func interfaceID(iface NetworkInterface) string {
+	if iface.HardwareAddress != nil && iface.Kind == Physical {
+		return iface.HardwareAddress.String()
+	}
+	return iface.Name
+}
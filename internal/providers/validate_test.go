@@ -0,0 +1,184 @@
+package providers
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func mustParseCIDR(s string) net.IPNet {
+	ip, n, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	return net.IPNet{IP: ip, Mask: n.Mask}
+}
+
+func TestValidateNetwork(t *testing.T) {
+	mac0, _ := net.ParseMAC("52:54:00:12:34:00")
+	mac1, _ := net.ParseMAC("52:54:00:12:34:01")
+	defaultV4 := mustParseCIDR("0.0.0.0/0")
+
+	tests := []struct {
+		desc string
+		in   []NetworkInterface
+		out  []NetworkInterface
+		err  string
+	}{
+		{
+			desc: "mapped addresses and duplicates",
+			in: []NetworkInterface{{
+				HardwareAddress: mac0,
+				IPAddresses: []net.IPNet{
+					{IP: net.ParseIP("10.0.0.5"), Mask: net.CIDRMask(120, 128)},
+					mustParseCIDR("10.0.0.5/24"),
+				},
+				Routes: []NetworkRoute{{Destination: defaultV4, Gateway: net.ParseIP("10.0.0.1")}},
+			}},
+			out: []NetworkInterface{{
+				HardwareAddress: mac0,
+				IPAddresses:     []net.IPNet{mustParseCIDR("10.0.0.5/24")},
+				Routes:          []NetworkRoute{{Destination: defaultV4, Gateway: net.IPv4(10, 0, 0, 1).To4()}},
+			}},
+		},
+		{
+			desc: "tied default routes and on-link gateway",
+			in: []NetworkInterface{
+				{
+					HardwareAddress: mac0,
+					IPAddresses:     []net.IPNet{mustParseCIDR("192.0.2.10/32")},
+					Routes:          []NetworkRoute{{Destination: defaultV4, Gateway: net.ParseIP("192.0.2.1")}},
+				},
+				{
+					HardwareAddress: mac1,
+					IPAddresses:     []net.IPNet{mustParseCIDR("10.0.0.5/24")},
+					Routes:          []NetworkRoute{{Destination: defaultV4, Gateway: net.ParseIP("10.0.0.1")}},
+				},
+			},
+			out: []NetworkInterface{
+				{
+					HardwareAddress: mac0,
+					IPAddresses:     []net.IPNet{mustParseCIDR("192.0.2.10/32")},
+					Routes:          []NetworkRoute{{Destination: defaultV4, Gateway: net.IPv4(192, 0, 2, 1).To4(), OnLink: true}},
+				},
+				{
+					HardwareAddress: mac1,
+					IPAddresses:     []net.IPNet{mustParseCIDR("10.0.0.5/24")},
+					Routes:          []NetworkRoute{{Destination: defaultV4, Gateway: net.IPv4(10, 0, 0, 1).To4(), Metric: 1}},
+				},
+			},
+		},
+		{
+			// the providers build default routes this way
+			desc: "provider default route mask",
+			in: []NetworkInterface{{
+				HardwareAddress: mac0,
+				IPAddresses:     []net.IPNet{{IP: net.ParseIP("203.0.113.10"), Mask: net.IPMask(net.ParseIP("255.255.255.0"))}},
+				Routes: []NetworkRoute{{
+					Destination: net.IPNet{IP: net.IPv4zero, Mask: net.IPMask(net.IPv4zero)},
+					Gateway:     net.ParseIP("203.0.113.1"),
+				}},
+			}},
+			out: []NetworkInterface{{
+				HardwareAddress: mac0,
+				IPAddresses:     []net.IPNet{mustParseCIDR("203.0.113.10/24")},
+				Routes:          []NetworkRoute{{Destination: defaultV4, Gateway: net.IPv4(203, 0, 113, 1).To4()}},
+			}},
+		},
+		{
+			desc: "route destination with host bits",
+			in: []NetworkInterface{{
+				HardwareAddress: mac0,
+				IPAddresses:     []net.IPNet{mustParseCIDR("10.0.0.5/16")},
+				Routes: []NetworkRoute{{
+					Destination: net.IPNet{IP: net.ParseIP("10.0.0.5"), Mask: net.CIDRMask(16, 32)},
+					Gateway:     net.ParseIP("10.0.0.1"),
+				}},
+			}},
+			out: []NetworkInterface{{
+				HardwareAddress: mac0,
+				IPAddresses:     []net.IPNet{mustParseCIDR("10.0.0.5/16")},
+				Routes: []NetworkRoute{{
+					Destination: net.IPNet{IP: net.IPv4(10, 0, 0, 0).To4(), Mask: net.CIDRMask(16, 32)},
+					Gateway:     net.IPv4(10, 0, 0, 1).To4(),
+				}},
+			}},
+		},
+		{
+			desc: "ipv4 address with ipv6 mask",
+			in: []NetworkInterface{{
+				HardwareAddress: mac0,
+				IPAddresses:     []net.IPNet{{IP: net.ParseIP("10.0.0.5"), Mask: net.CIDRMask(64, 128)}},
+			}},
+			err: "IPv4 address has an IPv6 mask",
+		},
+		{
+			desc: "address on two interfaces",
+			in: []NetworkInterface{
+				{HardwareAddress: mac0, IPAddresses: []net.IPNet{mustParseCIDR("10.0.0.5/24")}},
+				{HardwareAddress: mac1, IPAddresses: []net.IPNet{mustParseCIDR("10.0.0.5/16")}},
+			},
+			err: "also assigned to 52:54:00:12:34:00",
+		},
+		{
+			desc: "ipv6 address with ipv4 mask",
+			in: []NetworkInterface{{
+				HardwareAddress: mac0,
+				IPAddresses:     []net.IPNet{{IP: net.ParseIP("2001:db8::1"), Mask: net.CIDRMask(24, 32)}},
+			}},
+			err: "IPv6 address has an IPv4 mask",
+		},
+		{
+			desc: "mixed family route",
+			in: []NetworkInterface{{
+				HardwareAddress: mac0,
+				IPAddresses:     []net.IPNet{mustParseCIDR("10.0.0.5/24")},
+				Routes:          []NetworkRoute{{Destination: defaultV4, Gateway: net.ParseIP("2001:db8::1")}},
+			}},
+			err: "different address family",
+		},
+		{
+			desc: "unreachable gateway",
+			in: []NetworkInterface{{
+				HardwareAddress: mac0,
+				IPAddresses:     []net.IPNet{mustParseCIDR("2001:db8::5/64")},
+				Routes:          []NetworkRoute{{Destination: defaultV4, Gateway: net.ParseIP("10.0.0.1")}},
+			}},
+			err: "unreachable",
+		},
+		{
+			desc: "gateway behind dhcp",
+			in: []NetworkInterface{{
+				Name:   "eth0",
+				DHCP:   DHCPv4,
+				Routes: []NetworkRoute{{Destination: mustParseCIDR("10.1.0.0/16"), Gateway: net.ParseIP("10.0.0.1")}},
+			}},
+			out: []NetworkInterface{{
+				Name:   "eth0",
+				DHCP:   DHCPv4,
+				Routes: []NetworkRoute{{Destination: mustParseCIDR("10.1.0.0/16"), Gateway: net.IPv4(10, 0, 0, 1).To4()}},
+			}},
+		},
+	}
+
+	for _, test := range tests {
+		out, err := ValidateNetwork(test.in)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s: want error containing %q, got %v", test.desc, test.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.desc, err)
+			continue
+		}
+		if !reflect.DeepEqual(out, test.out) {
+			t.Errorf("%s: bad interfaces:\nwant %+v\ngot  %+v", test.desc, test.out, out)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vmware
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Backend provides access to the raw guestinfo variables of the VM.
+type Backend interface {
+	// Get returns the value of the "guestinfo.<key>" variable, or an empty
+	// string if it is not set.
+	Get(key string) (string, error)
+}
+
+// RPCToolBackend reads guestinfo variables using vmware-rpctool.
+type RPCToolBackend struct {
+	// Path is the vmware-rpctool binary; it is looked up in $PATH if empty.
+	Path string
+}
+
+func (b RPCToolBackend) Get(key string) (string, error) {
+	path := b.Path
+	if path == "" {
+		path = "vmware-rpctool"
+	}
+
+	out, err := exec.Command(path, "info-get guestinfo."+key).Output()
+	if _, ok := err.(*exec.ExitError); ok {
+		// vmware-rpctool exits with a failure when the variable is unset.
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(out)), nil
+}
+
+// FileBackend reads guestinfo variables from a directory containing one file
+// per variable, named after the variable (e.g. "guestinfo.metadata").
+type FileBackend struct {
+	Dir string
+}
+
+func (b FileBackend) Get(key string) (string, error) {
+	out, err := ioutil.ReadFile(filepath.Join(b.Dir, "guestinfo."+key))
+	if os.IsNotExist(err) {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(string(out)), nil
+}
@@ -0,0 +1,186 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package vmware reads cloud-init style metadata and user-data from the
+// VM's guestinfo variables.
+package vmware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/coreos/coreos-metadata/internal/providers"
+	"github.com/coreos/coreos-metadata/internal/providers/cloudinit"
+	"github.com/coreos/coreos-metadata/internal/providers/netconfig"
+
+	"gopkg.in/yaml.v2"
+)
+
+type MetaData struct {
+	InstanceID     string      `yaml:"instance-id" json:"instance-id"`
+	LocalHostname  string      `yaml:"local-hostname" json:"local-hostname"`
+	PublicKeys     interface{} `yaml:"public-keys" json:"public-keys"`
+	PublicKeysData string      `yaml:"public-keys-data" json:"public-keys-data"`
+
+	// Network is either an inline network-config document, or one
+	// encoded as described by NetworkEncoding.
+	Network         interface{} `yaml:"network" json:"network"`
+	NetworkEncoding string      `yaml:"network.encoding" json:"network.encoding"`
+}
+
+// Schema describes the attributes produced by FetchMetadata.
+var Schema = providers.Schema{
+	{Name: "VMWARE_INSTANCE_ID", Description: "The instance ID from guestinfo.metadata", Type: providers.TypeString, Optional: true},
+	{Name: "VMWARE_HOSTNAME", Description: "The hostname from guestinfo.metadata", Type: providers.TypeString, Optional: true},
+}
+
+// FetchMetadata reads guestinfo using vmware-rpctool.
+func FetchMetadata() (providers.Metadata, error) {
+	return FetchMetadataFrom(RPCToolBackend{})
+}
+
+// FetchMetadataFrom reads guestinfo using the given backend.
+func FetchMetadataFrom(backend Backend) (providers.Metadata, error) {
+	body, err := getDecoded(backend, "metadata")
+	if err != nil {
+		return providers.Metadata{}, err
+	}
+	if body == nil {
+		return providers.Metadata{}, fmt.Errorf("guestinfo.metadata is not set")
+	}
+
+	meta, err := parseMetaData(body)
+	if err != nil {
+		return providers.Metadata{}, fmt.Errorf("failed to unmarshal metadata: %v", err)
+	}
+
+	body, err = getDecoded(backend, "userdata")
+	if err != nil {
+		return providers.Metadata{}, err
+	}
+
+	user, err := cloudinit.ParseUserData(body)
+	if err != nil {
+		return providers.Metadata{}, fmt.Errorf("failed to unmarshal userdata: %v", err)
+	}
+
+	hostname := cloudinit.Hostname(meta.LocalHostname, user)
+
+	// the keys in public-keys-data come first
+	keys, err := cloudinit.ParsePublicKeys([]interface{}{meta.PublicKeysData, meta.PublicKeys})
+	if err != nil {
+		return providers.Metadata{}, err
+	}
+
+	network, err := parseNetwork(meta)
+	if err != nil {
+		return providers.Metadata{}, fmt.Errorf("failed to parse network config from metadata: %v", err)
+	}
+
+	return providers.Metadata{
+		Attributes: map[string]string{
+			"VMWARE_INSTANCE_ID": meta.InstanceID,
+			"VMWARE_HOSTNAME":    hostname,
+		},
+		Hostname: hostname,
+		Network:  network,
+		SshKeys:  append(keys, user.SshAuthorizedKeys...),
+		Common:   providers.Common{InstanceID: meta.InstanceID},
+	}, nil
+}
+
+// parseMetaData reads metadata in either JSON or YAML. JSON is decoded
+// separately, since YAML parsers reject some valid JSON, e.g. the "\/"
+// escape.
+func parseMetaData(body []byte) (MetaData, error) {
+	var meta MetaData
+	if bytes.HasPrefix(bytes.TrimSpace(body), []byte("{")) {
+		err := json.Unmarshal(body, &meta)
+		return meta, err
+	}
+	err := yaml.Unmarshal(body, &meta)
+	return meta, err
+}
+
+// getDecoded reads the given guestinfo variable and decodes it according to
+// the accompanying "<key>.encoding" variable. nil is returned if the
+// variable is not set.
+func getDecoded(backend Backend, key string) ([]byte, error) {
+	value, err := backend.Get(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read guestinfo.%s: %v", key, err)
+	}
+	if value == "" {
+		return nil, nil
+	}
+
+	encoding, err := backend.Get(key + ".encoding")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read guestinfo.%s.encoding: %v", key, err)
+	}
+
+	body, err := decode(value, encoding)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode guestinfo.%s: %v", key, err)
+	}
+	return body, nil
+}
+
+func decode(value, encoding string) ([]byte, error) {
+	switch encoding {
+	case "":
+		return []byte(value), nil
+	case "base64", "b64":
+		return base64.StdEncoding.DecodeString(value)
+	case "gzip+base64", "gz+b64":
+		compressed, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, err
+		}
+
+		reader, err := gzip.NewReader(bytes.NewReader(compressed))
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+
+		return ioutil.ReadAll(reader)
+	default:
+		return nil, fmt.Errorf("unsupported encoding %q", encoding)
+	}
+}
+
+func parseNetwork(meta MetaData) ([]providers.NetworkInterface, error) {
+	var body []byte
+	switch v := meta.Network.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		var err error
+		if body, err = decode(v, meta.NetworkEncoding); err != nil {
+			return nil, err
+		}
+	default:
+		var err error
+		if body, err = yaml.Marshal(v); err != nil {
+			return nil, err
+		}
+	}
+
+	return netconfig.Parse(body)
+}
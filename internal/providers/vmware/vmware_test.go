@@ -0,0 +1,195 @@
+package vmware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/coreos/coreos-metadata/internal/providers"
+)
+
+type mapBackend map[string]string
+
+func (b mapBackend) Get(key string) (string, error) {
+	return b[key], nil
+}
+
+func gzipBase64(s string) string {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write([]byte(s))
+	w.Close()
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+func TestFetchMetadataFrom(t *testing.T) {
+	const yamlMeta = "instance-id: vm-42\nlocal-hostname: esx-guest\npublic-keys-data: |\n  ssh-rsa AAAA alice\n"
+	const jsonMeta = `{"instance-id": "vm-42", "local-hostname": "esx-guest", "public-keys": ["ssh-rsa AAAA alice"]}`
+	const userData = "#cloud-config\nssh_authorized_keys:\n  - ssh-ed25519 BBBB bob\n"
+
+	want := providers.Metadata{
+		Attributes: map[string]string{
+			"VMWARE_INSTANCE_ID": "vm-42",
+			"VMWARE_HOSTNAME":    "esx-guest",
+		},
+		Hostname: "esx-guest",
+		SshKeys:  []string{"ssh-rsa AAAA alice", "ssh-ed25519 BBBB bob"},
+		Common:   providers.Common{InstanceID: "vm-42"},
+	}
+
+	tests := []struct {
+		desc    string
+		backend mapBackend
+		err     bool
+	}{
+		{
+			desc: "plain yaml",
+			backend: mapBackend{
+				"metadata": yamlMeta,
+				"userdata": userData,
+			},
+		},
+		{
+			desc: "base64 json",
+			backend: mapBackend{
+				"metadata":          base64.StdEncoding.EncodeToString([]byte(jsonMeta)),
+				"metadata.encoding": "base64",
+				"userdata":          base64.StdEncoding.EncodeToString([]byte(userData)),
+				"userdata.encoding": "b64",
+			},
+		},
+		{
+			desc: "gzip and base64",
+			backend: mapBackend{
+				"metadata":          gzipBase64(yamlMeta),
+				"metadata.encoding": "gzip+base64",
+				"userdata":          gzipBase64(userData),
+				"userdata.encoding": "gz+b64",
+			},
+		},
+		{
+			desc: "public-keys map",
+			backend: mapBackend{
+				"metadata": "instance-id: vm-42\nlocal-hostname: esx-guest\npublic-keys:\n  alice: ssh-rsa AAAA alice\n",
+				"userdata": userData,
+			},
+		},
+		{
+			desc: "json with escapes",
+			backend: mapBackend{
+				"metadata": `{"instance-id": "vm\u002d42", "local-hostname": "esx-guest", "public-keys": {"alice": "ssh-rsa AAAA alice"}, "network": null}`,
+				"userdata": userData,
+			},
+		},
+		{
+			desc:    "missing metadata",
+			backend: mapBackend{},
+			err:     true,
+		},
+		{
+			desc: "unknown encoding",
+			backend: mapBackend{
+				"metadata":          yamlMeta,
+				"metadata.encoding": "rot13",
+			},
+			err: true,
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := FetchMetadataFrom(tt.backend)
+		if tt.err {
+			if err == nil {
+				t.Errorf("%s: expected an error", tt.desc)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.desc, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s:\nwant: %#v\n got: %#v", tt.desc, want, got)
+		}
+		if err := Schema.Validate(got.Attributes); err != nil {
+			t.Errorf("%s: %v", tt.desc, err)
+		}
+	}
+}
+
+func TestParseMetaData(t *testing.T) {
+	meta, err := parseMetaData([]byte(`{"instance-id": "a\/b", "network": {"version": 2}}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if meta.InstanceID != "a/b" {
+		t.Errorf("bad instance ID: want %q, got %q", "a/b", meta.InstanceID)
+	}
+	if _, err := parseNetwork(meta); err != nil {
+		t.Errorf("unexpected network error: %v", err)
+	}
+}
+
+func TestFileBackend(t *testing.T) {
+	dir, err := ioutil.TempDir("", "guestinfo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "guestinfo.metadata"), []byte("instance-id: vm-42\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	backend := FileBackend{Dir: dir}
+	if value, err := backend.Get("metadata"); err != nil || value != "instance-id: vm-42" {
+		t.Errorf("metadata: got %q, %v", value, err)
+	}
+	if value, err := backend.Get("userdata"); err != nil || value != "" {
+		t.Errorf("userdata: got %q, %v", value, err)
+	}
+}
+
+func TestParseNetwork(t *testing.T) {
+	const network = "version: 2\nethernets:\n  eth0:\n    addresses: [10.0.0.5/24]\n"
+
+	tests := []struct {
+		desc string
+		meta MetaData
+	}{
+		{
+			desc: "inline",
+			meta: MetaData{Network: map[interface{}]interface{}{
+				"version": 2,
+				"ethernets": map[interface{}]interface{}{
+					"eth0": map[interface{}]interface{}{
+						"addresses": []interface{}{"10.0.0.5/24"},
+					},
+				},
+			}},
+		},
+		{
+			desc: "base64",
+			meta: MetaData{
+				Network:         base64.StdEncoding.EncodeToString([]byte(network)),
+				NetworkEncoding: "base64",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		ifaces, err := parseNetwork(tt.meta)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.desc, err)
+			continue
+		}
+		if len(ifaces) != 1 || ifaces[0].Name != "eth0" || len(ifaces[0].IPAddresses) != 1 {
+			t.Errorf("%s: unexpected interfaces: %#v", tt.desc, ifaces)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vultr
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/coreos/coreos-metadata/internal/providers"
+	"github.com/coreos/coreos-metadata/internal/retry"
+)
+
+var (
+	metadataEndpoint = "http://169.254.169.254/v1.json"
+)
+
+type IPv4 struct {
+	Address string `json:"address"`
+	Netmask string `json:"netmask"`
+	Gateway string `json:"gateway"`
+}
+
+type IPv6 struct {
+	Address string `json:"address"`
+	Network string `json:"network"`
+	Prefix  string `json:"prefix"`
+}
+
+type Interface struct {
+	IPv4        *IPv4  `json:"ipv4"`
+	IPv6        *IPv6  `json:"ipv6"`
+	MAC         string `json:"mac"`
+	NetworkType string `json:"network-type"`
+}
+
+type Region struct {
+	RegionCode string `json:"regioncode"`
+}
+
+type Metadata struct {
+	Hostname   string      `json:"hostname"`
+	InstanceID string      `json:"instanceid"`
+	Interfaces []Interface `json:"interfaces"`
+	PublicKeys []string    `json:"public-keys"`
+	Region     Region      `json:"region"`
+}
+
+// Schema describes the attributes produced by FetchMetadata.
+var Schema = providers.Schema{
+	{Name: "VULTR_HOSTNAME", Description: "The hostname of the instance", Type: providers.TypeString},
+	{Name: "VULTR_INSTANCE_ID", Description: "The ID of the instance", Type: providers.TypeString},
+	{Name: "VULTR_REGION", Description: "The region code of the instance", Type: providers.TypeString, Optional: true},
+	{Name: "VULTR_IPV4_PUBLIC", Description: "The IPv4 address of the nth public interface", Type: providers.TypeIPv4, Indexed: true},
+	{Name: "VULTR_IPV6_PUBLIC", Description: "The IPv6 address of the nth public interface", Type: providers.TypeIPv6, Indexed: true},
+	{Name: "VULTR_IPV4_PRIVATE", Description: "The IPv4 address of the nth private interface", Type: providers.TypeIPv4, Indexed: true},
+	{Name: "VULTR_IPV6_PRIVATE", Description: "The IPv6 address of the nth private interface", Type: providers.TypeIPv6, Indexed: true},
+}
+
+func FetchMetadata() (providers.Metadata, error) {
+	body, err := retry.Client{
+		InitialBackoff: time.Second,
+		MaxBackoff:     time.Second * 5,
+		MaxAttempts:    10,
+	}.Get(metadataEndpoint)
+	if err != nil {
+		return providers.Metadata{}, fmt.Errorf("failed to fetch metadata: %v", err)
+	}
+
+	var m Metadata
+	if err = json.Unmarshal(body, &m); err != nil {
+		return providers.Metadata{}, fmt.Errorf("failed to unmarshal metadata: %v", err)
+	}
+
+	network, err := parseNetwork(m)
+	if err != nil {
+		return providers.Metadata{}, fmt.Errorf("failed to parse network config from metadata: %v", err)
+	}
+
+	return providers.Metadata{
+		Attributes: parseAttributes(m),
+		Hostname:   m.Hostname,
+		Network:    network,
+		SshKeys:    m.PublicKeys,
+		Common:     parseCommon(m),
+	}, nil
+}
+
+func parseCommon(metadata Metadata) providers.Common {
+	common := providers.Common{
+		Region:     metadata.Region.RegionCode,
+		InstanceID: metadata.InstanceID,
+	}
+	for _, iface := range metadata.Interfaces {
+		if iface.IPv4 == nil {
+			continue
+		}
+		ip := net.ParseIP(iface.IPv4.Address)
+		switch {
+		case iface.NetworkType == "public" && common.PublicIPv4 == nil:
+			common.PublicIPv4 = ip
+		case iface.NetworkType == "private" && common.PrivateIPv4 == nil:
+			common.PrivateIPv4 = ip
+		}
+	}
+
+	return common
+}
+
+func parseAttributes(metadata Metadata) map[string]string {
+	attrs := map[string]string{
+		"VULTR_HOSTNAME":    metadata.Hostname,
+		"VULTR_INSTANCE_ID": metadata.InstanceID,
+		"VULTR_REGION":      metadata.Region.RegionCode,
+	}
+
+	var public, private int
+	for _, iface := range metadata.Interfaces {
+		var kind string
+		var i int
+		switch iface.NetworkType {
+		case "public":
+			kind, i = "PUBLIC", public
+			public++
+		case "private":
+			kind, i = "PRIVATE", private
+			private++
+		default:
+			continue
+		}
+
+		if iface.IPv4 != nil {
+			attrs[fmt.Sprintf("VULTR_IPV4_%s_%d", kind, i)] =
+				providers.String(net.ParseIP(iface.IPv4.Address))
+		}
+		if iface.IPv6 != nil {
+			attrs[fmt.Sprintf("VULTR_IPV6_%s_%d", kind, i)] =
+				providers.String(net.ParseIP(iface.IPv6.Address))
+		}
+	}
+
+	return attrs
+}
+
+func parseNetwork(metadata Metadata) ([]providers.NetworkInterface, error) {
+	var ifaces []providers.NetworkInterface
+	for _, iface := range metadata.Interfaces {
+		mac, err := net.ParseMAC(iface.MAC)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse %q as MAC address", iface.MAC)
+		}
+
+		config := providers.NetworkInterface{
+			HardwareAddress: mac,
+			Role:            iface.NetworkType,
+		}
+
+		if iface.IPv4 != nil {
+			addr, err := parseIPv4Address(*iface.IPv4)
+			if err != nil {
+				return nil, err
+			}
+			config.IPAddresses = append(config.IPAddresses, addr)
+
+			if iface.IPv4.Gateway != "" {
+				gateway := net.ParseIP(iface.IPv4.Gateway)
+				if gateway == nil {
+					return nil, fmt.Errorf("could not parse %q as gateway address", iface.IPv4.Gateway)
+				}
+
+				config.Routes = append(config.Routes, providers.NetworkRoute{
+					Destination: net.IPNet{
+						IP:   net.IPv4zero,
+						Mask: net.IPMask(net.IPv4zero),
+					},
+					Gateway: gateway,
+				})
+			}
+		}
+
+		if iface.IPv6 != nil {
+			addr, err := parseIPv6Address(*iface.IPv6)
+			if err != nil {
+				return nil, err
+			}
+			config.IPAddresses = append(config.IPAddresses, addr)
+		}
+
+		ifaces = append(ifaces, config)
+	}
+
+	return ifaces, nil
+}
+
+func parseIPv4Address(address IPv4) (net.IPNet, error) {
+	ip := net.ParseIP(address.Address)
+	if ip == nil {
+		return net.IPNet{}, fmt.Errorf("could not parse %q as IPv4 address", address.Address)
+	}
+
+	mask := net.ParseIP(address.Netmask)
+	if mask == nil {
+		return net.IPNet{}, fmt.Errorf("could not parse %q as IPv4 mask", address.Netmask)
+	}
+
+	return net.IPNet{
+		IP:   ip,
+		Mask: net.IPMask(mask.To4()),
+	}, nil
+}
+
+func parseIPv6Address(address IPv6) (net.IPNet, error) {
+	ip := net.ParseIP(address.Address)
+	if ip == nil {
+		return net.IPNet{}, fmt.Errorf("could not parse %q as IPv6 address", address.Address)
+	}
+
+	prefix, err := strconv.Atoi(address.Prefix)
+	if err != nil || prefix < 0 || prefix > net.IPv6len*8 {
+		return net.IPNet{}, fmt.Errorf("could not parse %q as IPv6 prefix length", address.Prefix)
+	}
+
+	return net.IPNet{
+		IP:   ip,
+		Mask: net.CIDRMask(prefix, net.IPv6len*8),
+	}, nil
+}
@@ -0,0 +1,118 @@
+package vultr
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/coreos/coreos-metadata/internal/providers"
+)
+
+func TestFetchMetadata(t *testing.T) {
+	server := httptest.NewServer(http.FileServer(http.Dir("testdata")))
+	defer server.Close()
+
+	tests := []struct {
+		desc     string
+		endpoint string
+		metadata providers.Metadata
+		err      bool
+	}{
+		{
+			desc:     "public and private interfaces",
+			endpoint: server.URL + "/v1.json",
+			metadata: providers.Metadata{
+				Attributes: map[string]string{
+					"VULTR_HOSTNAME":       "guest",
+					"VULTR_INSTANCE_ID":    "a747bfz6385e",
+					"VULTR_REGION":         "EWR",
+					"VULTR_IPV4_PUBLIC_0":  "45.76.7.171",
+					"VULTR_IPV6_PUBLIC_0":  "2001:19f0:5:28a7:5400:1ff:feb7:67a3",
+					"VULTR_IPV4_PRIVATE_0": "10.1.96.3",
+				},
+				Hostname: "guest",
+				SshKeys: []string{
+					"ssh-rsa AAAAB3NzaC1yc2EAAAADAQABAAABAQC0g+ZTxC7weoIJLUafOgrm+h core@example",
+				},
+				Network: []providers.NetworkInterface{
+					{
+						HardwareAddress: mustParseMAC("56:00:01:b7:67:a3"),
+						Role:            "public",
+						IPAddresses: []net.IPNet{
+							{
+								IP:   net.ParseIP("45.76.7.171"),
+								Mask: net.IPv4Mask(255, 255, 254, 0),
+							},
+							{
+								IP:   net.ParseIP("2001:19f0:5:28a7:5400:1ff:feb7:67a3"),
+								Mask: net.CIDRMask(64, 128),
+							},
+						},
+						Routes: []providers.NetworkRoute{
+							{
+								Destination: net.IPNet{
+									IP:   net.IPv4zero,
+									Mask: net.IPMask(net.IPv4zero),
+								},
+								Gateway: net.ParseIP("45.76.6.1"),
+							},
+						},
+					},
+					{
+						HardwareAddress: mustParseMAC("5a:00:01:b7:67:a3"),
+						Role:            "private",
+						IPAddresses: []net.IPNet{
+							{
+								IP:   net.ParseIP("10.1.96.3"),
+								Mask: net.IPv4Mask(255, 255, 240, 0),
+							},
+						},
+					},
+				},
+				Common: providers.Common{
+					PrivateIPv4: net.ParseIP("10.1.96.3"),
+					PublicIPv4:  net.ParseIP("45.76.7.171"),
+					Region:      "EWR",
+					InstanceID:  "a747bfz6385e",
+				},
+			},
+		},
+		{
+			desc:     "malformed metadata",
+			endpoint: server.URL + "/invalid.json",
+			err:      true,
+		},
+	}
+
+	defer func(endpoint string) { metadataEndpoint = endpoint }(metadataEndpoint)
+	for _, tt := range tests {
+		metadataEndpoint = tt.endpoint
+		metadata, err := FetchMetadata()
+		if tt.err {
+			if err == nil {
+				t.Errorf("%s: expected an error", tt.desc)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.desc, err)
+			continue
+		}
+		if !reflect.DeepEqual(metadata, tt.metadata) {
+			t.Errorf("%s:\nwant: %#v\n got: %#v", tt.desc, tt.metadata, metadata)
+		}
+		if err := Schema.Validate(metadata.Attributes); err != nil {
+			t.Errorf("%s: %v", tt.desc, err)
+		}
+	}
+}
+
+func mustParseMAC(s string) net.HardwareAddr {
+	mac, err := net.ParseMAC(s)
+	if err != nil {
+		panic(err)
+	}
+	return mac
+}
@@ -0,0 +1,182 @@
+// Copyright 2017 CoreOS, Inc.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package render renders text/template files with the metadata, so that
+// configuration files can be generated from it directly.
+//
+// The templates are executed with the providers.Metadata as their data, and
+// have the following functions in addition to the builtin ones:
+//
+//	attr NAME            the attribute NAME, or "" if it is not set
+//	indexed PREFIX       the attributes PREFIX_0, PREFIX_1, ... in order
+//	addresses            the addresses of all interfaces
+//	ipv4 ADDRS           the IPv4 addresses among ADDRS
+//	ipv6 ADDRS           the IPv6 addresses among ADDRS
+//	first LIST           the first element of LIST, or "" if it is empty
+//	default DEF VALUE    VALUE, or DEF if VALUE is empty
+//	join SEP LIST        the elements of LIST joined by SEP
+//
+// ADDRS may be an address, a network, an interface, a string or a list of
+// any of these.
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"reflect"
+	"strings"
+	"text/template"
+
+	"github.com/coreos/coreos-metadata/internal/providers"
+)
+
+// File renders the template in the file at path.
+func File(path string, metadata providers.Metadata) ([]byte, error) {
+	text, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Render(path, string(text), metadata)
+}
+
+// Render renders the template text, using name in error messages.
+func Render(name, text string, metadata providers.Metadata) ([]byte, error) {
+	tmpl, err := template.New(name).Option("missingkey=zero").Funcs(Funcs(metadata)).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, metadata); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// Funcs returns the helper functions available to templates.
+func Funcs(metadata providers.Metadata) template.FuncMap {
+	return template.FuncMap{
+		"attr": func(name string) string {
+			return metadata.Attributes[name]
+		},
+		"indexed": func(prefix string) []string {
+			var values []string
+			for i := 0; ; i++ {
+				value, ok := metadata.Attributes[fmt.Sprintf("%s_%d", prefix, i)]
+				if !ok {
+					return values
+				}
+				values = append(values, value)
+			}
+		},
+		"addresses": func() []net.IPNet {
+			var addrs []net.IPNet
+			for _, iface := range metadata.Network {
+				addrs = append(addrs, iface.IPAddresses...)
+			}
+			return addrs
+		},
+		"ipv4": func(addrs interface{}) ([]net.IP, error) {
+			return filterFamily(addrs, true)
+		},
+		"ipv6": func(addrs interface{}) ([]net.IP, error) {
+			return filterFamily(addrs, false)
+		},
+		"first": func(list interface{}) interface{} {
+			v := reflect.ValueOf(list)
+			if !v.IsValid() || (v.Kind() != reflect.Slice && v.Kind() != reflect.Array) {
+				return list
+			}
+			if v.Len() == 0 {
+				return ""
+			}
+			return v.Index(0).Interface()
+		},
+		"default": func(def, value interface{}) interface{} {
+			v := reflect.ValueOf(value)
+			if !v.IsValid() {
+				return def
+			}
+			switch v.Kind() {
+			case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+				if v.Len() == 0 {
+					return def
+				}
+			}
+			return value
+		},
+		"join": func(sep string, list interface{}) (string, error) {
+			v := reflect.ValueOf(list)
+			if !v.IsValid() {
+				return "", nil
+			}
+			if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+				return "", fmt.Errorf("join: cannot join %T", list)
+			}
+			var elems []string
+			for i := 0; i < v.Len(); i++ {
+				elems = append(elems, fmt.Sprint(v.Index(i).Interface()))
+			}
+			return strings.Join(elems, sep), nil
+		},
+	}
+}
+
+func filterFamily(addrs interface{}, v4 bool) ([]net.IP, error) {
+	var ips []net.IP
+	var collect func(v interface{}) error
+	collect = func(v interface{}) error {
+		switch v := v.(type) {
+		case nil:
+		case net.IP:
+			ips = append(ips, v)
+		case net.IPNet:
+			ips = append(ips, v.IP)
+		case string:
+			if ip := net.ParseIP(v); ip != nil {
+				ips = append(ips, ip)
+			} else if ip, _, err := net.ParseCIDR(v); err == nil {
+				ips = append(ips, ip)
+			}
+		case providers.NetworkInterface:
+			for _, addr := range v.IPAddresses {
+				ips = append(ips, addr.IP)
+			}
+		default:
+			rv := reflect.ValueOf(v)
+			if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+				return fmt.Errorf("not an address: %T", v)
+			}
+			for i := 0; i < rv.Len(); i++ {
+				if err := collect(rv.Index(i).Interface()); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	if err := collect(addrs); err != nil {
+		return nil, err
+	}
+
+	var filtered []net.IP
+	for _, ip := range ips {
+		if (ip.To4() != nil) == v4 {
+			filtered = append(filtered, ip)
+		}
+	}
+	return filtered, nil
+}
@@ -0,0 +1,68 @@
+package render
+
+import (
+	"net"
+	"testing"
+
+	"github.com/coreos/coreos-metadata/internal/providers"
+)
+
+func TestRender(t *testing.T) {
+	metadata := providers.Metadata{
+		Hostname: "node1",
+		Attributes: map[string]string{
+			"DIGITALOCEAN_IPV4_PRIVATE_0": "10.0.0.5",
+			"DIGITALOCEAN_IPV4_PUBLIC_0":  "203.0.113.5",
+			"DIGITALOCEAN_IPV4_PUBLIC_1":  "203.0.113.6",
+			"DIGITALOCEAN_IPV6_PUBLIC_0":  "2001:db8::5",
+		},
+		Network: []providers.NetworkInterface{{
+			IPAddresses: []net.IPNet{
+				{IP: net.ParseIP("10.0.0.5"), Mask: net.CIDRMask(24, 32)},
+				{IP: net.ParseIP("2001:db8::5"), Mask: net.CIDRMask(64, 128)},
+			},
+		}},
+	}
+
+	tests := []struct {
+		desc string
+		text string
+		out  string
+	}{
+		{
+			desc: "fields and attributes",
+			text: `{{.Hostname}} {{attr "DIGITALOCEAN_IPV4_PRIVATE_0"}} {{.Attributes.DIGITALOCEAN_IPV4_PRIVATE_0}}`,
+			out:  "node1 10.0.0.5 10.0.0.5",
+		},
+		{
+			desc: "indexed",
+			text: `{{join "," (indexed "DIGITALOCEAN_IPV4_PUBLIC")}}`,
+			out:  "203.0.113.5,203.0.113.6",
+		},
+		{
+			desc: "family filters",
+			text: `{{first (ipv4 addresses)}} {{join " " (ipv6 .Network)}} {{first (ipv6 (attr "DIGITALOCEAN_IPV4_PUBLIC_0"))}}`,
+			out:  "10.0.0.5 2001:db8::5 ",
+		},
+		{
+			desc: "defaults",
+			text: `{{default "none" .Attributes.MISSING}} {{default "none" (indexed "MISSING")}} {{default "none" .Hostname}}`,
+			out:  "none none node1",
+		},
+	}
+
+	for _, test := range tests {
+		out, err := Render(test.desc, test.text, metadata)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", test.desc, err)
+			continue
+		}
+		if string(out) != test.out {
+			t.Errorf("%s: want %q, got %q", test.desc, test.out, out)
+		}
+	}
+
+	if _, err := Render("bad address", `{{ipv4 .Volumes}}`, providers.Metadata{Volumes: []providers.Volume{{}}}); err == nil {
+		t.Error("expected an error filtering non-addresses")
+	}
+}
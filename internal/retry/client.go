@@ -15,6 +15,7 @@
 package retry
 
 import (
+	"bytes"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -26,26 +27,55 @@ type Client struct {
 	MaxBackoff     time.Duration
 	MaxAttempts    int
 	Header         http.Header
+
+	// Transport is used to make requests when set, instead of the default.
+	Transport http.RoundTripper
 }
 
 func (c Client) Get(url string) ([]byte, error) {
+	return c.do("GET", url, nil)
+}
+
+func (c Client) Post(url string, body []byte) ([]byte, error) {
+	return c.do("POST", url, body)
+}
+
+func (c Client) Put(url string, body []byte) ([]byte, error) {
+	return c.do("PUT", url, body)
+}
+
+func (c Client) Patch(url string, body []byte) ([]byte, error) {
+	return c.do("PATCH", url, body)
+}
+
+func (c Client) do(method string, url string, body []byte) ([]byte, error) {
 	delay := c.InitialBackoff
 	for attempt := 1; attempt <= c.MaxAttempts; attempt++ {
-		fmt.Printf("Fetching %q: Attempt #%d\n", url, attempt)
+		if method == "GET" {
+			fmt.Printf("Fetching %q: Attempt #%d\n", url, attempt)
+		} else {
+			fmt.Printf("%s %q: Attempt #%d\n", method, url, attempt)
+		}
 
-		request, err := http.NewRequest("GET", url, nil)
+		request, err := http.NewRequest(method, url, bytes.NewReader(body))
 		if err != nil {
 			return nil, err
 		}
 
 		request.Header = c.Header
 
-		if response, err := (&http.Client{}).Do(request); err != nil {
-			fmt.Printf("Failed to fetch: %v\n", err)
-		} else if response.StatusCode == http.StatusNotFound {
+		if response, err := (&http.Client{Transport: c.Transport}).Do(request); err != nil {
+			fmt.Printf("Failed to %s: %v\n", verb(method), err)
+		} else if response.StatusCode == http.StatusNotFound && method == "GET" {
+			response.Body.Close()
 			return nil, nil
-		} else if response.StatusCode != http.StatusOK {
-			fmt.Printf("Failed to fetch: %s\n", http.StatusText(response.StatusCode))
+		} else if response.StatusCode >= 400 && response.StatusCode <= 499 {
+			// the request won't succeed by repeating it
+			response.Body.Close()
+			return nil, fmt.Errorf("failed to %s %q: %s", verb(method), url, http.StatusText(response.StatusCode))
+		} else if !succeeded(method, response.StatusCode) {
+			response.Body.Close()
+			fmt.Printf("Failed to %s: %s\n", verb(method), http.StatusText(response.StatusCode))
 		} else {
 			defer response.Body.Close()
 			return ioutil.ReadAll(response.Body)
@@ -58,7 +88,27 @@ func (c Client) Get(url string) ([]byte, error) {
 		}
 	}
 
-	return nil, fmt.Errorf("timed out while fetching %q", url)
+	if method == "GET" {
+		return nil, fmt.Errorf("timed out while fetching %q", url)
+	}
+	return nil, fmt.Errorf("timed out while sending %s to %q", method, url)
+}
+
+// succeeded reports whether a response has the expected status. Fetches
+// expect 200 OK, while updates may be answered with any 2xx status, e.g. 204
+// No Content.
+func succeeded(method string, status int) bool {
+	if method == "GET" {
+		return status == http.StatusOK
+	}
+	return status >= 200 && status <= 299
+}
+
+func verb(method string) string {
+	if method == "GET" {
+		return "fetch"
+	}
+	return method
 }
 
 func (c Client) Getf(format string, a ...interface{}) ([]byte, error) {
@@ -0,0 +1,62 @@
+package retry
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClientStatus(t *testing.T) {
+	tests := []struct {
+		desc     string
+		method   string
+		status   int
+		attempts int
+		body     string
+		err      bool
+	}{
+		{desc: "get ok", method: "GET", status: http.StatusOK, attempts: 1, body: "ok"},
+		{desc: "get not found", method: "GET", status: http.StatusNotFound, attempts: 1},
+		{desc: "get forbidden", method: "GET", status: http.StatusForbidden, attempts: 1, err: true},
+		{desc: "get unavailable", method: "GET", status: http.StatusServiceUnavailable, attempts: 3, err: true},
+		{desc: "post no content", method: "POST", status: http.StatusNoContent, attempts: 1},
+		{desc: "post not found", method: "POST", status: http.StatusNotFound, attempts: 1, err: true},
+		{desc: "post bad request", method: "POST", status: http.StatusBadRequest, attempts: 1, err: true},
+		{desc: "post server error", method: "POST", status: http.StatusInternalServerError, attempts: 3, err: true},
+	}
+
+	for _, tt := range tests {
+		var attempts int
+		status, body := tt.status, tt.body
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			attempts++
+			w.WriteHeader(status)
+			w.Write([]byte(body))
+		}))
+
+		client := Client{
+			InitialBackoff: time.Millisecond,
+			MaxBackoff:     time.Millisecond,
+			MaxAttempts:    3,
+		}
+		var got []byte
+		var err error
+		if tt.method == "GET" {
+			got, err = client.Get(server.URL)
+		} else {
+			got, err = client.Post(server.URL, nil)
+		}
+		server.Close()
+
+		if tt.err != (err != nil) {
+			t.Errorf("%s: unexpected error: %v", tt.desc, err)
+		}
+		if attempts != tt.attempts {
+			t.Errorf("%s: want %d attempts, got %d", tt.desc, tt.attempts, attempts)
+		}
+		if !tt.err && string(got) != tt.body {
+			t.Errorf("%s: want body %q, got %q", tt.desc, tt.body, got)
+		}
+	}
+}